@@ -0,0 +1,63 @@
+// Command search embeds a text query and returns the top-k image-tagger
+// results (from an index built by "image-tagger tag -index_path ...") by
+// cosine similarity.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/StrongerSoftworks/image-tagger/internal/index"
+	"github.com/ollama/ollama/api"
+)
+
+func main() {
+	var indexPath, embedModel string
+	var topK int
+	var help bool
+
+	flag.StringVar(&indexPath, "index_path", "", "Path to the SQLite database built by 'image-tagger tag -index_path ...'")
+	flag.StringVar(&embedModel, "embed_model", "nomic-embed-text", "Ollama embedding model to vectorize the query with (default: nomic-embed-text)")
+	flag.IntVar(&topK, "top", 10, "Number of results to return (default: 10)")
+	flag.BoolVar(&help, "help", false, "Show help")
+	flag.Parse()
+
+	if help || indexPath == "" || flag.NArg() == 0 {
+		fmt.Println("Usage: search -index_path <path> \"query\"")
+		flag.PrintDefaults()
+		return
+	}
+	query := flag.Arg(0)
+
+	db, err := index.Open(indexPath)
+	if err != nil {
+		slog.Error("Error opening index", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ollamaClient, err := api.ClientFromEnvironment()
+	if err != nil {
+		slog.Error("Error creating ollama client", "error", err)
+		os.Exit(1)
+	}
+
+	vector, err := index.Embed(context.Background(), ollamaClient, embedModel, query)
+	if err != nil {
+		slog.Error("Error embedding query", "error", err)
+		os.Exit(1)
+	}
+
+	results, err := db.Search(vector, topK)
+	if err != nil {
+		slog.Error("Error searching index", "error", err)
+		os.Exit(1)
+	}
+
+	for _, result := range results {
+		fmt.Printf("%.4f  %s  %s - %s\n", result.Score, result.Filename, result.Subject, result.Description)
+	}
+}
@@ -6,63 +6,73 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
-	"path"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/StrongerSoftworks/image-tagger/internal/imageloader"
 	"github.com/StrongerSoftworks/image-tagger/internal/imagetiler"
+	"github.com/StrongerSoftworks/image-tagger/internal/index"
+	"github.com/StrongerSoftworks/image-tagger/internal/ocr"
+	"github.com/StrongerSoftworks/image-tagger/internal/pipeline"
+	"github.com/StrongerSoftworks/image-tagger/internal/preproc"
+	"github.com/StrongerSoftworks/image-tagger/internal/vision"
 	"github.com/ollama/ollama/api"
 )
 
-type ImageData struct {
-	File        string           `json:"file"`
-	Processed   time.Time        `json:"processed"`
-	Subject     string           `json:"subject"`
-	Description string           `json:"description"`
-	Tags        []VisionModelTag `json:"tags"`
+// decodableExtensions are the file extensions image-tagger can decode, see
+// internal/imagereader.Decode.
+var decodableExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".webp": true,
+	".avif": true,
+	".tiff": true,
+	".tif":  true,
 }
 
-type VisionModelTag struct {
-	Object     string `json:"object"`
-	Confidence int    `json:"confidence"`
-}
-
-type VisionModelTags struct {
-	Subject string           `json:"subject"`
-	Tags    []VisionModelTag `json:"tags"`
-}
-
-type VisionModelSummary struct {
-	Subject     string `json:"subject"`
-	Description string `json:"description"`
-}
-
-var visionModel string
-
-const confidenceThreshold = 50
-
 func main() {
 	// Command line arguments
-	var imageFilePath, tagsFilePath, outputPath string
-	var cropSize, cropWidth, cropHeight int
+	var imageFilePath, dirPath, tagsFilePath, outputPath, visionModel, backend string
+	var ocrBackend, tesseractPath string
+	var indexPath, embedModel string
+	var preprocSpec string
+	var cropSize, cropWidth, cropHeight, maxCrops, smartCropStride, dedupHammingThreshold, concurrency int
+	var maxImageMB int64
+	var smartCropMinScore float64
 	var mode string
-	var saveCropped bool
+	var saveCropped, recursive, failFast, preserveMetadata, smartCropSaliency bool
 	var debugMode bool
 	var help bool
 
 	flag.StringVar(&imageFilePath, "image", "", "Path to the image to process")
+	flag.StringVar(&dirPath, "dir", "", "Path to a directory of images to process instead of a single -image")
+	flag.BoolVar(&recursive, "recursive", false, "Used with -dir. Recurse into subdirectories (default: false)")
+	flag.IntVar(&concurrency, "concurrency", 1, "Used with -dir. Number of images to process concurrently (default: 1)")
+	flag.BoolVar(&failFast, "fail_fast", true, "Used with -dir. Stop the batch on the first error instead of logging it and continuing (default: true)")
 	flag.StringVar(&tagsFilePath, "tags_path", "", "Path to the tags file")
 	flag.StringVar(&outputPath, "out", "out", "Path to save the tiled images")
 	flag.StringVar(&visionModel, "vision_model", "llava:13b", "Model to use for vision (default: llava:13b)")
+	flag.StringVar(&backend, "backend", "ollama", "Vision backend to use: 'ollama', 'openai' or 'llamacpp' (default: ollama)")
 	flag.IntVar(&cropWidth, "width", 672, "Resize width (default: 672)")
 	flag.IntVar(&cropHeight, "height", 672, "Resize height (default: 672)")
-	flag.IntVar(&cropSize, "crop", 672, "Used with mode=tile. Crop width and height. Uses max_crops to create smaller images from the image and sending each image to the vision model (default: 512)")
-	flag.StringVar(&mode, "mode", "tile", "'fit' or 'tile'. 'fit' will resize the image to fit the given width and height. 'tile' will resize the image to fit \"crop\" x \"crop\" then process the image in 4 tiles with max width and height of \"crop\".")
+	flag.IntVar(&cropSize, "crop", 672, "Used with mode=tile or mode=smart. Crop width and height. Uses max_crops to create smaller images from the image and sending each image to the vision model (default: 672)")
+	flag.IntVar(&maxCrops, "max_crops", 6, "Used with mode=tile or mode=smart. Max images to crop from the source image. (default: 6)")
+	flag.StringVar(&mode, "mode", "tile", "'fit', 'tile' or 'smart'. 'fit' will resize the image to fit the given width and height. 'tile' will resize the image to fit \"crop\" x \"crop\" then process the image in 4 tiles with max width and height of \"crop\". 'smart' picks the \"max_crops\" most visually interesting \"crop\" x \"crop\" regions instead of a fixed grid.")
+	flag.IntVar(&smartCropStride, "smart_crop_stride", 0, "Used with mode=smart. Pixel step between candidate crop windows (default: crop/4)")
+	flag.Float64Var(&smartCropMinScore, "smart_crop_min_score", 0, "Used with mode=smart. Minimum average interest score a candidate crop window must have to be considered (default: 0, no minimum)")
+	flag.BoolVar(&smartCropSaliency, "smart_crop_saliency", false, "Used with mode=smart. Blend a spectral-residual saliency map into the interest score (default: false)")
+	flag.IntVar(&dedupHammingThreshold, "dedup_hamming_threshold", 0, "Used with mode=tile or mode=smart. Drop tiles whose perceptual hash is less than this Hamming distance from an already-kept tile (default: 0, deduplication disabled)")
+	flag.BoolVar(&preserveMetadata, "preserve_metadata", false, "Encode tile 0 as JPEG with a re-embedded EXIF block (orientation, DateTimeOriginal, GPS) instead of PNG (default: false)")
+	flag.Int64Var(&maxImageMB, "max_image_mb", 0, "Reject images whose decoded pixel footprint would exceed this many megabytes instead of decoding them (default: 0, no limit)")
+	flag.StringVar(&ocrBackend, "ocr_backend", "", "Run OCR on each image and fold recognized text into the summary prompt: 'tesseract' or 'ollama' (default: \"\", disabled)")
+	flag.StringVar(&tesseractPath, "tesseract_path", "", "Used with ocr_backend=tesseract. Path to the tesseract binary (default: \"tesseract\" from PATH)")
+	flag.StringVar(&indexPath, "index_path", "", "Path to a SQLite database to persist results in for 'image-tagger search' (default: \"\", disabled)")
+	flag.StringVar(&embedModel, "embed_model", "nomic-embed-text", "Used with index_path. Ollama embedding model to vectorize results with (default: nomic-embed-text)")
+	flag.StringVar(&preprocSpec, "preproc", "", "Comma-separated cleanup steps to run before tiling: 'grayscale', 'sauvola', 'deskew', 'wipe' (default: \"\", disabled)")
 	flag.BoolVar(&saveCropped, "save", false, "Save cropped images. For debugging purposes. Images that are saved are not automatically deleted by image-tagger.")
 	flag.BoolVar(&debugMode, "debug", false, "Enable debug mode")
 	flag.BoolVar(&help, "help", false, "Show help")
@@ -80,258 +90,229 @@ func main() {
 		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
 	}
 
-	if imageFilePath == "" {
-		fmt.Println("Image file path or URL must be provided.")
+	if imageFilePath == "" && dirPath == "" {
+		fmt.Println("Either -image or -dir must be provided.")
 		flag.PrintDefaults()
 		return
 	}
 
 	start := time.Now()
 
-	// read tags
 	desiredTags := readTagsFilter(tagsFilePath)
 
-	slog.Info("Processing image", "imagePath", imageFilePath)
-
-	options := imagetiler.Options{
-		SaveCropped: saveCropped,
-		ImagePath:   imageFilePath,
-		OutputDir:   outputPath,
-		Width:       cropWidth,
-		Height:      cropHeight,
-		CropSize:    cropSize,
-		Mode:        imagetiler.Mode(mode),
-	}
-
-	img := imageloader.LoadImage(options.ImagePath)
-	images := imagetiler.MakeImageTiles(options, img)
-
 	ollamaClient, err := api.ClientFromEnvironment()
 	if err != nil {
 		slog.Error("Error creating ollama client", "error", err)
 		return
 	}
 
-	var imagesData []api.ImageData = make([]api.ImageData, len(images))
-	for idx, image := range images {
-		imagesData[idx] = image
-	}
-
-	summary := generateImageSummary(ollamaClient, imagesData)
-	summaryTags := generateImageTags(ollamaClient, imagesData, summary.Subject, desiredTags)
-	imageDataWithTags := ImageData{
-		File:        filepath.Base(imageFilePath),
-		Processed:   time.Now(),
-		Subject:     summary.Subject,
-		Description: summary.Description,
-		Tags:        summaryTags,
-	}
-
-	jsonData, err := json.Marshal(imageDataWithTags)
+	visionBackend, err := vision.New(vision.Kind(backend), visionModel, ollamaClient)
 	if err != nil {
-		slog.Error("Error marshaling tags to JSON", "error", err)
+		slog.Error("Error creating vision backend", "error", err)
 		return
 	}
 
-	// Write JSON to file with image name as prefix
-	jsonFileName := fmt.Sprintf("%s_tags.json", filepath.Base(imageFilePath))
-	err = os.WriteFile(path.Join(outputPath, jsonFileName), jsonData, 0644)
+	ocrEngine, err := newOCREngine(ocrBackend, tesseractPath, ollamaClient, visionModel)
 	if err != nil {
-		slog.Error("Error writing tags to file", "error", err)
+		slog.Error("Error creating OCR engine", "error", err)
 		return
 	}
 
-	slog.Info("Completed", "time", time.Since(start))
-}
-
-// readTagsFilter reads the tags file and returns a string of tags
-func readTagsFilter(filePath string) []string {
-	// Open the file
-	file, err := os.Open(filePath)
+	preprocPipeline, err := preproc.ParsePipeline(preprocSpec)
 	if err != nil {
-		slog.Error("Error opening tags file", "error", err)
-		return []string{}
+		slog.Error("Error parsing preproc pipeline", "error", err)
+		return
 	}
-	defer file.Close()
 
-	// Create a Scanner to read the file
-	var tags []string
-	fileContents, err := io.ReadAll(file)
-	if err != nil {
-		slog.Error("Error reading tags file", "error", err)
-		return []string{}
-	}
-	err = json.Unmarshal(fileContents, &tags)
-	if err != nil {
-		slog.Error("Error unmarshalling tags", "error", err)
-		return []string{}
+	var imageIndex *index.DB
+	if indexPath != "" {
+		imageIndex, err = index.Open(indexPath)
+		if err != nil {
+			slog.Error("Error opening index", "error", err)
+			return
+		}
+		defer imageIndex.Close()
 	}
 
-	return tags
-}
+	opts := pipeline.Options{
+		Backend:     visionBackend,
+		OCREngine:   ocrEngine,
+		Index:       imageIndex,
+		EmbedClient: ollamaClient,
+		EmbedModel:  embedModel,
+		Preproc:     preprocPipeline,
+		TilerOptions: imagetiler.Options{
+			SaveCropped:           saveCropped,
+			OutputDir:             outputPath,
+			Width:                 cropWidth,
+			Height:                cropHeight,
+			CropSize:              cropSize,
+			MaxCrops:              maxCrops,
+			Mode:                  imagetiler.Mode(mode),
+			SmartCropStride:       smartCropStride,
+			SmartCropMinScore:     smartCropMinScore,
+			UseSaliency:           smartCropSaliency,
+			DedupHammingThreshold: dedupHammingThreshold,
+			PreserveMetadata:      preserveMetadata,
+			MemoryBudgetBytes:     maxImageMB * 1024 * 1024,
+		},
+		DesiredTags: desiredTags,
+		OutputDir:   outputPath,
+	}
 
-func generateImageSummary(ollamaClient *api.Client, imagesData []api.ImageData) VisionModelSummary {
-	var wg sync.WaitGroup
-	results := make(chan VisionModelSummary, 1)
+	ctx := context.Background()
 
-	wg.Add(1)
-	go sendVisionSummaryRequest(ollamaClient, imagesData, &wg, results)
+	if dirPath != "" {
+		processDir(ctx, dirPath, recursive, concurrency, failFast, outputPath, opts)
+	} else {
+		slog.Info("Processing image", "imagePath", imageFilePath, "backend", backend)
+		if _, err := pipeline.Process(ctx, imageFilePath, opts); err != nil {
+			slog.Error("Error processing image", "error", err)
+			return
+		}
+	}
 
-	wg.Wait()
-	close(results)
+	slog.Info("Completed", "time", time.Since(start))
+}
 
-	return <-results
+// newOCREngine builds the OCR engine requested by -ocr_backend, or nil
+// when OCR is disabled.
+func newOCREngine(backend, tesseractPath string, ollamaClient *api.Client, visionModel string) (ocr.Engine, error) {
+	switch backend {
+	case "":
+		return nil, nil
+	case "tesseract":
+		return ocr.NewTesseractEngine(tesseractPath), nil
+	case "ollama":
+		return ocr.NewOllamaEngine(ollamaClient, visionModel), nil
+	default:
+		return nil, fmt.Errorf("unknown ocr_backend %q", backend)
+	}
 }
 
-// generateImageTags sends a generate request to the vision model running on the ollama client
-func generateImageTags(ollamaClient *api.Client, imagesData []api.ImageData, subject string, desiredTags []string) []VisionModelTag {
-	var wg sync.WaitGroup
-	results := make(chan VisionModelTags, 1)
+// processDir walks dirPath for decodable images and runs them through
+// pipeline.Process with a bounded worker pool, skipping files already
+// recorded in outputPath/index.json with a matching mod time and size so a
+// re-run can resume after a crash or partial batch.
+func processDir(ctx context.Context, dirPath string, recursive bool, concurrency int, failFast bool, outputPath string, opts pipeline.Options) {
+	index, err := pipeline.LoadIndex(filepath.Join(outputPath, "index.json"))
+	if err != nil {
+		slog.Error("Error loading index", "error", err)
+		return
+	}
 
-	wg.Add(1)
-	go sendVisionTagsRequest(ollamaClient, imagesData, subject, desiredTags, &wg, results)
+	paths := make(chan string)
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(paths)
+		walkErr <- filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if !recursive && path != dirPath {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !decodableExtensions[filepath.Ext(path)] {
+				return nil
+			}
+			paths <- path
+			return nil
+		})
+	}()
 
+	var failed bool
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				mu.Lock()
+				stop := failFast && failed
+				mu.Unlock()
+				if stop {
+					continue
+				}
+				if err := processFile(ctx, path, index, opts); err != nil {
+					slog.Error("Error processing image", "file", path, "error", err)
+					if failFast {
+						mu.Lock()
+						failed = true
+						mu.Unlock()
+					}
+				}
+			}
+		}()
+	}
 	wg.Wait()
-	close(results)
 
-	return collectUniqueTags(results)
+	if err := <-walkErr; err != nil {
+		slog.Error("Error walking directory", "error", err)
+	}
 }
 
-func sendVisionSummaryRequest(ollamaClient *api.Client, imagesData []api.ImageData, wg *sync.WaitGroup, summaries chan<- VisionModelSummary) {
-	prompt := "You are a professional SEO specialist. Analyze the provided image and provide:" +
-		"    subject: The main subject of the image as a single word. The subject can be an object or improper noun." +
-		"    description: A short description of the image no longer than 20 words." +
-		" No introductions, explanations, or extra text." +
-		" Respond using JSON."
-
-	request := &api.GenerateRequest{
-		Model:  visionModel,
-		Prompt: prompt,
-		Stream: new(bool),
-		Images: imagesData,
-		Format: []byte(`{
-			"type": "object",
-			"properties": {
-				"subject": { "type": "string" },
-				"description": { "type": "string" }
-			},
-			"required": [
-				"subject", "description"
-			]
-		}`),
+// processFile processes a single file, skipping it if the index already
+// has a result for its current mod time and size.
+func processFile(ctx context.Context, path string, index *pipeline.Index, opts pipeline.Options) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("resolving absolute path: %w", err)
 	}
 
-	responseHandler := func(response api.GenerateResponse) error {
-		slog.Debug("Summary response", "response", response.Response)
-		defer wg.Done()
-
-		var imageSummary VisionModelSummary
-		err := json.Unmarshal([]byte(response.Response), &imageSummary)
-		if err != nil {
-			slog.Error("Error unmarshalling summary", "error", err)
-			return err
-		}
-		summaries <- imageSummary
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
 
+	if _, ok := index.Lookup(absPath, info.ModTime(), info.Size()); ok {
+		slog.Debug("Skipping already-processed image", "file", path)
 		return nil
 	}
 
-	slog.Debug("Sending summary request", "request", request.Prompt)
-	err := ollamaClient.Generate(context.Background(), request, responseHandler)
+	fileStart := time.Now()
+	result, err := pipeline.Process(ctx, path, opts)
 	if err != nil {
-		slog.Error("Error sending generate request to ollama", "error", err)
-		wg.Done()
+		return err
 	}
-}
 
-// sendVisionRequest sends a generate request to the vision model running on the ollama client
-func sendVisionTagsRequest(ollamaClient *api.Client, imageData []api.ImageData, subject string, desiredTags []string, wg *sync.WaitGroup, summaries chan<- VisionModelTags) {
-	objectInstruction := "that are visible in the image"
-	if len(desiredTags) > 0 {
-		objectInstruction = fmt.Sprintf("from the following list: [%s]", strings.Join(desiredTags, ", "))
-	}
-	prompt := fmt.Sprintf("You are assembling a list of tags for a web application that will be used for browsing images and filtering images by tags."+
-		" Analyze the provided image of a %s and identify the objects %s."+
-		" If an object is found, provide: "+
-		"    object: An object from the list of objects."+
-		"    confidence: A confidence level number between 0 and 100 based on clarity, visibility, and similarity to known references."+
-		" The object should clearly be visible in the image and you must me confident that the object is correctly identified."+
-		" No introductions, explanations, or extra text."+
-		" Respond using JSON.", subject, objectInstruction)
-
-	request := &api.GenerateRequest{
-		Model:  visionModel,
-		Prompt: prompt,
-		Stream: new(bool),
-		Images: imageData,
-		Format: []byte(`{
-			"type": "object",
-			"properties": {
-				"tags": {
-					"type": "array",
-					"items": {
-						"type": "object",
-						"properties": {
-							"object": {
-								"type": "string"
-							},
-							"confidence": {
-								"type": "number"
-							}
-						},
-						"required": ["object", "confidence"]
-					}
-				}
-			},
-			"required": [
-				"tags"
-			]
-		}`),
+	if err := index.Store(absPath, info.ModTime(), info.Size(), result); err != nil {
+		return fmt.Errorf("storing index entry: %w", err)
 	}
 
-	responseHandler := func(response api.GenerateResponse) error {
-		slog.Debug("Tag response", "response", response.Response)
-		defer wg.Done()
-
-		var imageSummary VisionModelTags
-		err := json.Unmarshal([]byte(response.Response), &imageSummary)
-		if err != nil {
-			slog.Error("Error unmarshalling tags", "error", err)
-			return err
-		}
-		summaries <- imageSummary
+	slog.Info("Processed image", "file", path, "time", time.Since(fileStart))
+	return nil
+}
 
-		return nil
+// readTagsFilter reads the tags file and returns a string of tags
+func readTagsFilter(filePath string) []string {
+	if filePath == "" {
+		return []string{}
 	}
 
-	slog.Debug("Sending tag request", "request", request.Prompt)
-	err := ollamaClient.Generate(context.Background(), request, responseHandler)
+	// Open the file
+	file, err := os.Open(filePath)
 	if err != nil {
-		slog.Error("Error sending generate request to ollama", "error", err)
-		wg.Done()
+		slog.Error("Error opening tags file", "error", err)
+		return []string{}
 	}
-}
-
-// collectUniqueTags filters tags with confidence greater than the threshold and ensures uniqueness.
-func collectUniqueTags(summaryChan <-chan VisionModelTags) []VisionModelTag {
-	tagMap := make(map[string]VisionModelTag)
+	defer file.Close()
 
-	for summary := range summaryChan { // Read from the channel until it's closed
-		for _, tag := range summary.Tags {
-			if tag.Confidence >= confidenceThreshold {
-				// Store the tag in the map, keeping the highest confidence value
-				if existingTag, exists := tagMap[tag.Object]; !exists || tag.Confidence > existingTag.Confidence {
-					tagMap[tag.Object] = tag
-				}
-			}
-		}
+	// Create a Scanner to read the file
+	var tags []string
+	fileContents, err := io.ReadAll(file)
+	if err != nil {
+		slog.Error("Error reading tags file", "error", err)
+		return []string{}
 	}
-
-	// Convert map values to a slice
-	uniqueTags := make([]VisionModelTag, 0, len(tagMap))
-	for _, tag := range tagMap {
-		uniqueTags = append(uniqueTags, tag)
+	err = json.Unmarshal(fileContents, &tags)
+	if err != nil {
+		slog.Error("Error unmarshalling tags", "error", err)
+		return []string{}
 	}
 
-	return uniqueTags
+	return tags
 }
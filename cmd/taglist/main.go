@@ -1,20 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/StrongerSoftworks/image-tagger/internal/imageloader"
 	"github.com/StrongerSoftworks/image-tagger/internal/imagetiler"
 	"github.com/ollama/ollama/api"
 )
@@ -32,11 +36,6 @@ type VisionModelTag struct {
 	Confidence int    `json:"confidence"`
 }
 
-type VisionModelTags struct {
-	Subject string           `json:"subject"`
-	Tags    []VisionModelTag `json:"tags"`
-}
-
 type VisionModelSummary struct {
 	Subject     string `json:"subject"`
 	Description string `json:"description"`
@@ -51,14 +50,20 @@ const confidenceThreshold = 50
 
 func main() {
 	// Command line arguments
-	var imageFilePath, tagsFilePath, outputPath string
-	var cropSize, cropWidth, cropHeight, maxCrops int
+	var imageFilePath, tagsFilePath, outputPath, imagesDir, manifestPath string
+	var cropSize, cropWidth, cropHeight, maxCrops, concurrency int
+	var maxImageMB int64
 	var mode string
-	var saveCropped bool
+	var saveCropped, force bool
 	var debugMode bool
 	var help bool
+	var imageTimeout time.Duration
 
 	flag.StringVar(&imageFilePath, "image", "", "Path to the file that contains a list of image file paths")
+	flag.StringVar(&imagesDir, "images_dir", "", "Path to a directory of images to process concurrently instead of a single -image")
+	flag.StringVar(&manifestPath, "manifest", "", "Path to a JSONL manifest ({\"path\": \"...\"} per line) of images to process concurrently instead of a single -image")
+	flag.IntVar(&concurrency, "concurrency", 4, "Used with -images_dir or -manifest. Number of images to process concurrently, matching Ollama's default parallel request slots (default: 4)")
+	flag.BoolVar(&force, "force", false, "Used with -images_dir or -manifest. Bypass the on-disk result cache and reprocess every image (default: false)")
 	flag.StringVar(&tagsFilePath, "tags_path", "", "Path to the tags file")
 	flag.StringVar(&outputPath, "out", "out", "Path to save the tiled images")
 	flag.StringVar(&visionModel, "vision_model", "llava:13b", "Model to use for vision (default: llava:13b)")
@@ -67,8 +72,10 @@ func main() {
 	flag.IntVar(&cropSize, "crop", 672, "Used with mode=tile. Crop width and height. Uses max_crops to create smaller images from the image and sending each image to the vision model (default: 512)")
 	flag.IntVar(&maxCrops, "max_crops", 6, "Used with mode=tile. Max images to crop from the source image. The source image will be resized before cropping if it cannot be fully covered with size of cropped images. (default: 6)")
 	flag.StringVar(&mode, "mode", "fit", "'fit' or 'tile'. 'fit' will resize the image to fit the given width and height. 'tile' will resize the image to fit the given max pixels then process the image in tiles defined by width and height. (default: fit)")
+	flag.Int64Var(&maxImageMB, "max_image_mb", 0, "Reject images whose decoded pixel footprint would exceed this many megabytes instead of decoding them (default: 0, no limit)")
 	flag.BoolVar(&saveCropped, "save", false, "Save cropped images (default: false). For debugging purposes. Images that are saved are not automatically deleted by image-tagger.")
 	flag.BoolVar(&debugMode, "debug", false, "Enable debug mode (default: false)")
+	flag.DurationVar(&imageTimeout, "image_timeout", 0, "Per-image deadline for vision requests, e.g. \"2m\" (default: 0, no deadline)")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.Parse()
 
@@ -84,8 +91,8 @@ func main() {
 		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
 	}
 
-	if imageFilePath == "" {
-		fmt.Println("Image file path or URL must be provided.")
+	if imageFilePath == "" && imagesDir == "" && manifestPath == "" {
+		fmt.Println("Image file path or URL, -images_dir or -manifest must be provided.")
 		flag.PrintDefaults()
 		return
 	}
@@ -100,21 +107,64 @@ func main() {
 	// read tags
 	desiredTags := readTagsFilter(tagsFilePath)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if imagesDir != "" || manifestPath != "" {
+		ollamaClient, err := api.ClientFromEnvironment()
+		if err != nil {
+			slog.Error("Error creating ollama client", "error", err)
+			return
+		}
+
+		options := imagetiler.Options{
+			SaveCropped:       saveCropped,
+			OutputDir:         outputPath,
+			Width:             cropWidth,
+			Height:            cropHeight,
+			CropSize:          cropSize,
+			MaxCrops:          maxCrops,
+			Mode:              imagetiler.Mode(mode),
+			MemoryBudgetBytes: maxImageMB * 1024 * 1024,
+		}
+
+		paths, err := batchPaths(imagesDir, manifestPath)
+		if err != nil {
+			slog.Error("Error listing batch images", "error", err)
+			return
+		}
+
+		processBatch(ctx, ollamaClient, paths, desiredTags, options, outputPath, concurrency, force, imageTimeout)
+		slog.Info("Completed", "time", time.Since(start))
+		return
+	}
+
 	slog.Info("Processing image", "imagePath", imageFilePath)
 
 	options := imagetiler.Options{
-		SaveCropped: saveCropped,
-		ImagePath:   imageFilePath,
-		OutputDir:   outputPath,
-		Width:       cropWidth,
-		Height:      cropHeight,
-		CropSize:    cropSize,
-		MaxCrops:    maxCrops,
-		Mode:        imagetiler.Mode(mode),
+		SaveCropped:       saveCropped,
+		ImagePath:         imageFilePath,
+		OutputDir:         outputPath,
+		Width:             cropWidth,
+		Height:            cropHeight,
+		CropSize:          cropSize,
+		MaxCrops:          maxCrops,
+		Mode:              imagetiler.Mode(mode),
+		MemoryBudgetBytes: maxImageMB * 1024 * 1024,
 	}
 
-	img := imageloader.LoadImage(options.ImagePath)
-	images := imagetiler.MakeImageTiles(options, img)
+	imageCtx := ctx
+	if imageTimeout > 0 {
+		var cancel context.CancelFunc
+		imageCtx, cancel = context.WithTimeout(ctx, imageTimeout)
+		defer cancel()
+	}
+
+	images, err := loadTiles(imageCtx, options)
+	if err != nil {
+		slog.Error("Error loading image", "error", err)
+		return
+	}
 
 	ollamaClient, err := api.ClientFromEnvironment()
 	if err != nil {
@@ -122,8 +172,8 @@ func main() {
 		return
 	}
 
-	summary := generateImageSummary(ollamaClient, images[0])
-	summaryTags := generateImageTags(ollamaClient, images, summary.Subject, desiredTags)
+	summary := generateImageSummary(imageCtx, ollamaClient, images[0])
+	summaryTags := generateImageTags(imageCtx, ollamaClient, images, summary.Subject, desiredTags)
 	imageDataWithTags := ImageData{
 		File:        filepath.Base(imageFilePath),
 		Processed:   time.Now(),
@@ -149,6 +199,240 @@ func main() {
 	slog.Info("Completed", "time", time.Since(start))
 }
 
+// loadTiles runs options.ImagePath through an imagetiler.Tiler, collecting
+// the encoded tile bytes it produces. Routing through the Tiler rather than
+// decoding the whole image up front lets options.MemoryBudgetBytes reject
+// an oversized source before it's ever fully decoded.
+func loadTiles(ctx context.Context, options imagetiler.Options) ([][]byte, error) {
+	tiler := imagetiler.NewTiler(options)
+
+	var images [][]byte
+	for tile := range tiler.Tiles(ctx) {
+		images = append(images, tile.Bytes)
+	}
+	if err := tiler.Err(); err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("failed to load image %s", options.ImagePath)
+	}
+	return images, nil
+}
+
+// batchPaths returns the image paths a batch run should process, read from
+// imagesDir (non-recursive directory walk) or manifestPath (a JSONL file
+// with one {"path": "..."} object per line). Exactly one of the two is
+// expected to be non-empty.
+func batchPaths(imagesDir, manifestPath string) ([]string, error) {
+	if manifestPath != "" {
+		return readManifest(manifestPath)
+	}
+
+	var paths []string
+	err := filepath.WalkDir(imagesDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != imagesDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	return paths, err
+}
+
+// readManifest reads a JSONL manifest of {"path": "..."} entries.
+func readManifest(manifestPath string) ([]string, error) {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening manifest: %w", err)
+	}
+	defer file.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("parsing manifest line %q: %w", line, err)
+		}
+		paths = append(paths, entry.Path)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	return paths, nil
+}
+
+// processBatch runs paths through the tag/summarize pipeline with a
+// bounded worker pool, skipping images whose content fingerprint already
+// has a cached result (unless force is set), and logs a final hit/miss/
+// error summary.
+func processBatch(ctx context.Context, ollamaClient *api.Client, paths []string, desiredTags []string, options imagetiler.Options, outputPath string, concurrency int, force bool, imageTimeout time.Duration) {
+	jobs := make(chan string)
+	go func() {
+		defer close(jobs)
+		for _, p := range paths {
+			jobs <- p
+		}
+	}()
+
+	var hits, misses, errs int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				imageCtx, cancel := ctx, context.CancelFunc(func() {})
+				if imageTimeout > 0 {
+					imageCtx, cancel = context.WithTimeout(ctx, imageTimeout)
+				}
+				result, err := processBatchImage(imageCtx, ollamaClient, p, desiredTags, options, outputPath, force)
+				cancel()
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs++
+				case result:
+					hits++
+				default:
+					misses++
+				}
+				mu.Unlock()
+				if err != nil {
+					slog.Error("Error processing image", "file", p, "error", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	slog.Info("Batch complete", "hits", hits, "misses", misses, "errors", errs)
+}
+
+// processBatchImage processes a single image, returning true if a cached
+// result was reused (a "hit").
+func processBatchImage(ctx context.Context, ollamaClient *api.Client, imagePath string, desiredTags []string, options imagetiler.Options, outputPath string, force bool) (bool, error) {
+	fileOptions := options
+	fileOptions.ImagePath = imagePath
+
+	images, err := loadTiles(ctx, fileOptions)
+	if err != nil {
+		return false, err
+	}
+
+	hash := fingerprint(images, fileOptions, visionModel)
+	cachePath, err := resultCachePath(hash)
+	if err != nil {
+		return false, err
+	}
+
+	if !force {
+		if cached, ok := loadCachedResult(cachePath); ok {
+			if err := writeResultJSON(outputPath, imagePath, cached); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+
+	summary := generateImageSummary(ctx, ollamaClient, images[0])
+	summaryTags := generateImageTags(ctx, ollamaClient, images, summary.Subject, desiredTags)
+	result := ImageData{
+		File:        filepath.Base(imagePath),
+		Processed:   time.Now(),
+		Subject:     summary.Subject,
+		Description: summary.Description,
+		Tags:        summaryTags,
+	}
+
+	if err := writeResultJSON(outputPath, imagePath, result); err != nil {
+		return false, err
+	}
+	if err := storeCachedResult(cachePath, result); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func writeResultJSON(outputPath, imagePath string, result ImageData) error {
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshaling tags to JSON: %w", err)
+	}
+	jsonFileName := fmt.Sprintf("%s_tags.json", filepath.Base(imagePath))
+	if err := os.WriteFile(path.Join(outputPath, jsonFileName), jsonData, 0644); err != nil {
+		return fmt.Errorf("writing tags to file: %w", err)
+	}
+	return nil
+}
+
+// fingerprint computes a stable SHA-256 hash of the encoded tile bytes that
+// would be sent to the vision model plus the tiling parameters, model and
+// prompt templates that would otherwise affect the result, so a cached
+// result is only reused when none of those have changed.
+func fingerprint(images [][]byte, options imagetiler.Options, model string) string {
+	hash := sha256.New()
+
+	for _, tile := range images {
+		hash.Write(tile)
+	}
+
+	fmt.Fprintf(hash, "|%d|%d|%d|%d|%s|%s|%s|%s",
+		options.Width, options.Height, options.CropSize, options.MaxCrops, options.Mode, model, summaryPromptTemplate, tagsPromptTemplate)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// resultCachePath returns the on-disk path a cached result for hash is
+// stored at, creating its parent directory if needed.
+func resultCachePath(hash string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving user cache dir: %w", err)
+	}
+	resultsDir := filepath.Join(cacheDir, "image-tagger", "results")
+	if err := os.MkdirAll(resultsDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("creating cache dir: %w", err)
+	}
+	return filepath.Join(resultsDir, hash+".json"), nil
+}
+
+func loadCachedResult(cachePath string) (ImageData, bool) {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return ImageData{}, false
+	}
+	var result ImageData
+	if err := json.Unmarshal(data, &result); err != nil {
+		return ImageData{}, false
+	}
+	return result, true
+}
+
+func storeCachedResult(cachePath string, result ImageData) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshaling cache entry: %w", err)
+	}
+	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	return nil
+}
+
 // readTagsFilter reads the tags file and returns a string of tags
 func readTagsFilter(filePath string) []string {
 	// Open the file
@@ -175,12 +459,12 @@ func readTagsFilter(filePath string) []string {
 	return tags
 }
 
-func generateImageSummary(ollamaClient *api.Client, imageData []byte) VisionModelSummary {
+func generateImageSummary(ctx context.Context, ollamaClient *api.Client, imageData []byte) VisionModelSummary {
 	var wg sync.WaitGroup
 	results := make(chan VisionModelSummary, 1)
 
 	wg.Add(1)
-	go sendVisionSummaryRequest(ollamaClient, imageData, &wg, results)
+	go sendVisionSummaryRequest(ctx, ollamaClient, imageData, &wg, results)
 
 	wg.Wait() // Wait for all goroutines to finish
 	close(results)
@@ -188,33 +472,42 @@ func generateImageSummary(ollamaClient *api.Client, imageData []byte) VisionMode
 	return <-results
 }
 
-// generateImageTags sends a generate request to the vision model running on the ollama client
-func generateImageTags(ollamaClient *api.Client, images [][]byte, subject string, desiredTags []string) []VisionModelTag {
+// generateImageTags sends a generate request to the vision model running on
+// the ollama client. Tags are pushed onto the internal channel as soon as
+// the model finishes each one, so collectUniqueTags can start deduplicating
+// before the slowest tile's request completes.
+func generateImageTags(ctx context.Context, ollamaClient *api.Client, images [][]byte, subject string, desiredTags []string) []VisionModelTag {
 	var wg sync.WaitGroup
-	results := make(chan VisionModelTags, len(images))
+	results := make(chan VisionModelTag)
 
 	for _, imageData := range images {
 		wg.Add(1)
-		go sendVisionTagsRequest(ollamaClient, imageData, subject, desiredTags, &wg, results)
+		go sendVisionTagsRequest(ctx, ollamaClient, imageData, subject, desiredTags, &wg, results)
 	}
 
-	wg.Wait() // Wait for all goroutines to finish
-	close(results)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
 	return collectUniqueTags(results)
 }
 
-func sendVisionSummaryRequest(ollamaClient *api.Client, imageData []byte, wg *sync.WaitGroup, summaries chan<- VisionModelSummary) {
-	prompt := "Analyze the provided image and provide: " +
-		"    subject: The main subject of the image as a single word. " +
-		"    description: A short description of the image no longer than 20 words. " +
-		"No introductions, explanations, or extra text. " +
-		"Respond using JSON."
-
+// summaryPromptTemplate is the fixed instruction sent alongside every
+// image for the summary request, part of the batch cache fingerprint
+// since changing it changes the result a cached entry would stand in for.
+const summaryPromptTemplate = "Analyze the provided image and provide: " +
+	"    subject: The main subject of the image as a single word. " +
+	"    description: A short description of the image no longer than 20 words. " +
+	"No introductions, explanations, or extra text. " +
+	"Respond using JSON."
+
+func sendVisionSummaryRequest(ctx context.Context, ollamaClient *api.Client, imageData []byte, wg *sync.WaitGroup, summaries chan<- VisionModelSummary) {
+	stream := true
 	request := &api.GenerateRequest{
 		Model:  visionModel,
-		Prompt: prompt,
-		Stream: new(bool),
+		Prompt: summaryPromptTemplate,
+		Stream: &stream,
 		Images: []api.ImageData{imageData},
 		Format: []byte(`{
 			"type": "object",
@@ -228,13 +521,18 @@ func sendVisionSummaryRequest(ollamaClient *api.Client, imageData []byte, wg *sy
 		}`),
 	}
 
+	var buf strings.Builder
+	var once sync.Once
 	responseHandler := func(response api.GenerateResponse) error {
-		slog.Debug("Summary response", "response", response.Response)
-		defer wg.Done()
+		buf.WriteString(response.Response)
+		if !response.Done {
+			return nil
+		}
+		defer once.Do(wg.Done)
 
+		slog.Debug("Summary response", "response", buf.String())
 		var imageSummary VisionModelSummary
-		err := json.Unmarshal([]byte(response.Response), &imageSummary)
-		if err != nil {
+		if err := json.Unmarshal([]byte(buf.String()), &imageSummary); err != nil {
 			slog.Error("Error unmarshalling summary", "error", err)
 			return err
 		}
@@ -244,28 +542,33 @@ func sendVisionSummaryRequest(ollamaClient *api.Client, imageData []byte, wg *sy
 	}
 
 	slog.Debug("Sending summary request", "request", request.Prompt)
-	err := ollamaClient.Generate(context.Background(), request, responseHandler)
-	if err != nil {
+	if err := ollamaClient.Generate(ctx, request, responseHandler); err != nil {
 		slog.Error("Error sending generate request to ollama", "error", err)
-		wg.Done()
+		once.Do(wg.Done)
 	}
 }
 
 // sendVisionRequest sends a generate request to the vision model running on the ollama client
-func sendVisionTagsRequest(ollamaClient *api.Client, imageData []byte, subject string, desiredTags []string, wg *sync.WaitGroup, summaries chan<- VisionModelTags) {
-	prompt := fmt.Sprintf("Analyze the provided image of a %s and identify the objects from the following list: [%s]."+
-		"If an object is found, provide: "+
-		"    object: An object from the list of objects. "+
-		"    confidence: A confidence level number between 0 and 100 based on clarity, visibility, and similarity to known references. "+
-		// "Next include a summary of the description of an image and keep the summary to less than 20 words and summarize " +
-		// "as if describing the subject of the image focusing on the subject and ignoring what's around the main subject and use best practices for an HTML img alt tag. " +
-		"No introductions, explanations, or extra text. "+
-		"Respond using JSON.", subject, strings.Join(desiredTags, ", "))
-
+// tagsPromptTemplate is the fixed instruction sent alongside every image
+// for the tags request, with the subject and allowed-objects list
+// interpolated in at request time. It's part of the batch cache
+// fingerprint since changing it changes the result a cached entry would
+// stand in for.
+const tagsPromptTemplate = "Analyze the provided image of a %s and identify the objects from the following list: [%s]." +
+	"If an object is found, provide: " +
+	"    object: An object from the list of objects. " +
+	"    confidence: A confidence level number between 0 and 100 based on clarity, visibility, and similarity to known references. " +
+	"No introductions, explanations, or extra text. " +
+	"Respond using JSON."
+
+func sendVisionTagsRequest(ctx context.Context, ollamaClient *api.Client, imageData []byte, subject string, desiredTags []string, wg *sync.WaitGroup, tags chan<- VisionModelTag) {
+	prompt := fmt.Sprintf(tagsPromptTemplate, subject, strings.Join(desiredTags, ", "))
+
+	stream := true
 	request := &api.GenerateRequest{
 		Model:  visionModel,
 		Prompt: prompt,
-		Stream: new(bool),
+		Stream: &stream,
 		Images: []api.ImageData{imageData},
 		Format: []byte(`{
 			"type": "object",
@@ -292,40 +595,100 @@ func sendVisionTagsRequest(ollamaClient *api.Client, imageData []byte, subject s
 		}`),
 	}
 
+	var buf strings.Builder
+	var once sync.Once
+	consumed := 0
 	responseHandler := func(response api.GenerateResponse) error {
-		slog.Debug("Tag response", "response", response.Response)
-		defer wg.Done()
-
-		var imageSummary VisionModelTags
-		err := json.Unmarshal([]byte(response.Response), &imageSummary)
-		if err != nil {
-			slog.Error("Error unmarshalling tags", "error", err)
-			return err
+		buf.WriteString(response.Response)
+
+		var objects []string
+		objects, consumed = extractJSONObjects(buf.String(), consumed)
+		for _, obj := range objects {
+			var tag VisionModelTag
+			if err := json.Unmarshal([]byte(obj), &tag); err != nil {
+				slog.Error("Error unmarshalling tag", "error", err, "object", obj)
+				continue
+			}
+			tags <- tag
 		}
-		summaries <- imageSummary
 
+		if !response.Done {
+			return nil
+		}
+		once.Do(wg.Done)
+		slog.Debug("Tag response", "response", buf.String())
 		return nil
 	}
 
 	slog.Debug("Sending tag request", "request", request.Prompt)
-	err := ollamaClient.Generate(context.Background(), request, responseHandler)
-	if err != nil {
+	if err := ollamaClient.Generate(ctx, request, responseHandler); err != nil {
 		slog.Error("Error sending generate request to ollama", "error", err)
-		wg.Done()
+		once.Do(wg.Done)
+	}
+}
+
+// extractJSONObjects scans buf from the start for objects nested directly
+// inside the outer {"tags": [...]} response (i.e. opened at brace depth 2),
+// returning the ones that completed at or after consumed along with the new
+// consumed offset. Re-scanning from the start each call keeps brace depth
+// correct across calls at the cost of revisiting already-consumed bytes,
+// which is cheap relative to one HTTP round trip per streamed chunk. It
+// tolerates an in-progress, not-yet-valid-JSON tail, which is what a
+// streamed Ollama response looks like mid-generation.
+func extractJSONObjects(buf string, consumed int) ([]string, int) {
+	var objects []string
+	depth := 0
+	start := -1
+	inString := false
+	escaped := false
+	newConsumed := consumed
+
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			if depth == 1 {
+				start = i
+			}
+			depth++
+		case '}':
+			depth--
+			if depth == 1 && start >= 0 {
+				if start >= consumed {
+					objects = append(objects, buf[start:i+1])
+					newConsumed = i + 1
+				}
+				start = -1
+			}
+		}
 	}
+
+	return objects, newConsumed
 }
 
 // collectUniqueTags filters tags with confidence greater than the threshold and ensures uniqueness.
-func collectUniqueTags(summaryChan <-chan VisionModelTags) []VisionModelTag {
+func collectUniqueTags(tagChan <-chan VisionModelTag) []VisionModelTag {
 	tagMap := make(map[string]VisionModelTag)
 
-	for summary := range summaryChan { // Read from the channel until it's closed
-		for _, tag := range summary.Tags {
-			if tag.Confidence >= confidenceThreshold {
-				// Store the tag in the map, keeping the highest confidence value
-				if existingTag, exists := tagMap[tag.Object]; !exists || tag.Confidence > existingTag.Confidence {
-					tagMap[tag.Object] = tag
-				}
+	for tag := range tagChan { // Read from the channel until it's closed
+		if tag.Confidence >= confidenceThreshold {
+			// Store the tag in the map, keeping the highest confidence value
+			if existingTag, exists := tagMap[tag.Object]; !exists || tag.Confidence > existingTag.Confidence {
+				tagMap[tag.Object] = tag
 			}
 		}
 	}
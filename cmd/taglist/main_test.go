@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestExtractJSONObjectsIncremental feeds a full streamed tags response one
+// byte at a time, simulating how sendVisionTagsRequest sees it, and checks
+// that each tag object is reported exactly once, as soon as it completes.
+func TestExtractJSONObjectsIncremental(t *testing.T) {
+	full := `{"tags": [{"object": "cat", "confidence": 90}, {"object": "dog {nested}", "confidence": 70}]}`
+
+	var buf strings.Builder
+	var got []string
+	consumed := 0
+	for i := 0; i < len(full); i++ {
+		buf.WriteByte(full[i])
+		objects, newConsumed := extractJSONObjects(buf.String(), consumed)
+		consumed = newConsumed
+		got = append(got, objects...)
+	}
+
+	want := []string{
+		`{"object": "cat", "confidence": 90}`,
+		`{"object": "dog {nested}", "confidence": 70}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// TestExtractJSONObjectsNoDuplicatesOnRescan checks that calling
+// extractJSONObjects again on an unchanged buffer (no new bytes) returns
+// nothing, since every completed object is already past consumed.
+func TestExtractJSONObjectsNoDuplicatesOnRescan(t *testing.T) {
+	buf := `{"tags": [{"object": "cat", "confidence": 90}`
+	objects, consumed := extractJSONObjects(buf, 0)
+	if len(objects) != 1 {
+		t.Fatalf("first scan: got %d objects, want 1", len(objects))
+	}
+
+	objects, consumed = extractJSONObjects(buf, consumed)
+	if len(objects) != 0 {
+		t.Fatalf("rescan with no new bytes: got %d objects, want 0", len(objects))
+	}
+	_ = consumed
+}
+
+// TestExtractJSONObjectsIgnoresIncompleteTail checks that an object still
+// being streamed in (no closing brace yet) isn't reported early.
+func TestExtractJSONObjectsIgnoresIncompleteTail(t *testing.T) {
+	buf := `{"tags": [{"object": "cat", "confidence"`
+	objects, _ := extractJSONObjects(buf, 0)
+	if len(objects) != 0 {
+		t.Fatalf("got %d objects for an incomplete tail, want 0", len(objects))
+	}
+}
@@ -0,0 +1,459 @@
+package imagereader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Metadata carries the subset of EXIF data image-tagger cares about:
+// enough to correct orientation and to keep basic provenance (when/where a
+// photo was taken, and what camera produced it).
+type Metadata struct {
+	// Orientation is the raw EXIF orientation value (1-8). 0 means no
+	// orientation tag was present, which should be treated like 1
+	// (no transform needed).
+	Orientation int
+	Make        string
+	Model       string
+	// DateTimeOriginal is the zero Time if the tag was absent or
+	// unparseable.
+	DateTimeOriginal time.Time
+	HasGPS           bool
+	GPSLatitude      float64
+	GPSLongitude     float64
+}
+
+// tiffTag numbers used by ParseEXIF.
+const (
+	tagOrientation      = 0x0112
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagExifIFDPointer   = 0x8769
+	tagGPSInfoPointer   = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// exifDateLayout is the format EXIF uses for DateTimeOriginal, e.g.
+// "2024:03:05 14:22:10".
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// ParseEXIF extracts Metadata from a JPEG file's APP1 Exif segment, if
+// present. It returns (nil, nil) when data isn't a JPEG or has no Exif
+// segment, since that's the common, non-error case for PNG/WebP/AVIF
+// sources and for JPEGs with their metadata already stripped.
+func ParseEXIF(data []byte) (*Metadata, error) {
+	tiff := findExifTIFF(data)
+	if tiff == nil {
+		return nil, nil
+	}
+
+	order, err := tiffByteOrder(tiff)
+	if err != nil {
+		return nil, err
+	}
+
+	ifd0Offset := order.Uint32(tiff[4:8])
+	ifd0, err := readIFD(tiff, order, ifd0Offset)
+	if err != nil {
+		return nil, fmt.Errorf("imagereader: reading IFD0: %w", err)
+	}
+
+	md := &Metadata{}
+	if v, ok := ifd0[tagOrientation]; ok {
+		md.Orientation = int(v.asUint())
+	}
+	if v, ok := ifd0[tagMake]; ok {
+		md.Make = v.asString()
+	}
+	if v, ok := ifd0[tagModel]; ok {
+		md.Model = v.asString()
+	}
+
+	if v, ok := ifd0[tagExifIFDPointer]; ok {
+		exifIFD, err := readIFD(tiff, order, v.asUint())
+		if err == nil {
+			if dt, ok := exifIFD[tagDateTimeOriginal]; ok {
+				if t, err := time.Parse(exifDateLayout, dt.asString()); err == nil {
+					md.DateTimeOriginal = t
+				}
+			}
+		}
+	}
+
+	if v, ok := ifd0[tagGPSInfoPointer]; ok {
+		gpsIFD, err := readIFD(tiff, order, v.asUint())
+		if err == nil {
+			lat, latOK := gpsCoordinate(gpsIFD, tiff, order, tagGPSLatitude, tagGPSLatitudeRef, 'S')
+			lon, lonOK := gpsCoordinate(gpsIFD, tiff, order, tagGPSLongitude, tagGPSLongitudeRef, 'W')
+			if latOK && lonOK {
+				md.HasGPS = true
+				md.GPSLatitude = lat
+				md.GPSLongitude = lon
+			}
+		}
+	}
+
+	return md, nil
+}
+
+// findExifTIFF scans data for a JPEG APP1 "Exif\0\0" segment and returns
+// the TIFF structure that follows it, or nil if data isn't a JPEG or has
+// no such segment.
+func findExifTIFF(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil
+		}
+		marker := data[pos+1]
+		// SOS (start of scan) ends the header section; no point scanning further.
+		if marker == 0xDA {
+			return nil
+		}
+		segmentLen := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		segmentStart := pos + 4
+		segmentEnd := pos + 2 + segmentLen
+		if segmentEnd > len(data) {
+			return nil
+		}
+
+		if marker == 0xE1 && segmentEnd-segmentStart >= 6 && bytes.Equal(data[segmentStart:segmentStart+6], []byte("Exif\x00\x00")) {
+			return data[segmentStart+6 : segmentEnd]
+		}
+
+		pos = segmentEnd
+	}
+	return nil
+}
+
+func tiffByteOrder(tiff []byte) (binary.ByteOrder, error) {
+	if len(tiff) < 8 {
+		return nil, fmt.Errorf("imagereader: Exif TIFF header too short")
+	}
+	switch string(tiff[0:2]) {
+	case "II":
+		return binary.LittleEndian, nil
+	case "MM":
+		return binary.BigEndian, nil
+	default:
+		return nil, fmt.Errorf("imagereader: unrecognized TIFF byte order %q", tiff[0:2])
+	}
+}
+
+const (
+	ifdTypeByte     = 1
+	ifdTypeASCII    = 2
+	ifdTypeShort    = 3
+	ifdTypeLong     = 4
+	ifdTypeRational = 5
+)
+
+func trimNulTail(b []byte) string {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return string(b)
+}
+
+// readIFD reads the IFD at byteOffset (relative to the start of tiff) into
+// a map keyed by tag number. String and rational values larger than 4
+// bytes are resolved eagerly against tiff so entries remain valid after
+// this function returns.
+func readIFD(tiff []byte, order binary.ByteOrder, byteOffset uint32) (map[uint16]resolvedEntry, error) {
+	offset := int(byteOffset)
+	if offset < 0 || offset+2 > len(tiff) {
+		return nil, fmt.Errorf("imagereader: IFD offset %d out of range", byteOffset)
+	}
+
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	entries := make(map[uint16]resolvedEntry, count)
+	pos := offset + 2
+	for i := 0; i < count; i++ {
+		if pos+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[pos : pos+2])
+		typ := order.Uint16(tiff[pos+2 : pos+4])
+		cnt := order.Uint32(tiff[pos+4 : pos+8])
+		valueField := tiff[pos+8 : pos+12]
+
+		entries[tag] = resolveEntry(tiff, order, typ, cnt, valueField)
+		pos += 12
+	}
+	return entries, nil
+}
+
+// resolvedEntry holds an IFD entry's value already extracted from tiff, so
+// callers don't need to keep re-deriving offsets.
+type resolvedEntry struct {
+	typ     uint16
+	order   binary.ByteOrder
+	uintVal uint32
+	strVal  string
+	data    []byte // full value, for multi-valued entries like GPS RATIONAL triplets
+}
+
+func resolveEntry(tiff []byte, order binary.ByteOrder, typ uint16, count uint32, valueField []byte) resolvedEntry {
+	typeSize := map[uint16]int{ifdTypeByte: 1, ifdTypeASCII: 1, ifdTypeShort: 2, ifdTypeLong: 4, ifdTypeRational: 8}[typ]
+	total := typeSize * int(count)
+
+	inline := total <= 4
+	var data []byte
+	if inline {
+		data = valueField[:total]
+	} else {
+		offset := int(order.Uint32(valueField))
+		if offset >= 0 && offset+total <= len(tiff) {
+			data = tiff[offset : offset+total]
+		}
+	}
+
+	entry := resolvedEntry{typ: typ, order: order, data: data}
+	switch typ {
+	case ifdTypeASCII:
+		entry.strVal = trimNulTail(data)
+	case ifdTypeShort:
+		if len(data) >= 2 {
+			entry.uintVal = uint32(order.Uint16(data[:2]))
+		}
+	case ifdTypeLong:
+		if len(data) >= 4 {
+			entry.uintVal = order.Uint32(data[:4])
+		}
+	case ifdTypeByte:
+		if len(data) >= 1 {
+			entry.uintVal = uint32(data[0])
+		}
+	}
+	return entry
+}
+
+func (e resolvedEntry) asUint() uint32   { return e.uintVal }
+func (e resolvedEntry) asString() string { return e.strVal }
+
+// rational returns the i'th RATIONAL (numerator/denominator) packed into
+// a multi-valued entry's data, such as the 3 RATIONALs of a GPS
+// coordinate's degrees/minutes/seconds.
+func (e resolvedEntry) rational(i int) float64 {
+	offset := i * 8
+	if e.typ != ifdTypeRational || len(e.data) < offset+8 {
+		return 0
+	}
+	num := e.order.Uint32(e.data[offset : offset+4])
+	den := e.order.Uint32(e.data[offset+4 : offset+8])
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// gpsCoordinate reads a GPSLatitude/GPSLongitude tag (3 RATIONALs: degrees,
+// minutes, seconds) plus its ref tag ('N'/'S' or 'E'/'W') and converts it
+// to signed decimal degrees.
+func gpsCoordinate(gpsIFD map[uint16]resolvedEntry, tiff []byte, order binary.ByteOrder, coordTag, refTag uint16, negativeRef byte) (float64, bool) {
+	coord, ok := gpsIFD[coordTag]
+	ref, refOK := gpsIFD[refTag]
+	if !ok || !refOK || coord.typ != ifdTypeRational {
+		return 0, false
+	}
+
+	value := coord.rational(0) + coord.rational(1)/60 + coord.rational(2)/3600
+	if len(ref.strVal) > 0 && ref.strVal[0] == negativeRef {
+		value = -value
+	}
+	return value, true
+}
+
+// rawEntry is a to-be-written TIFF IFD entry: value holds the entry's full
+// value bytes (any length); encodeIFD inlines it if it fits in 4 bytes and
+// otherwise appends it to the IFD's own overflow area.
+type rawEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// encodeIFD lays out entries as a standalone IFD (count + 12 bytes/entry +
+// next-IFD-offset(0) + overflow area) positioned at baseOffset within the
+// eventual TIFF buffer, so overflow offsets it writes are correct once this
+// blob is placed there.
+func encodeIFD(order binary.ByteOrder, entries []rawEntry, baseOffset uint32) []byte {
+	var ifd bytes.Buffer
+	var overflow bytes.Buffer
+
+	ifdSize := 2 + 12*len(entries) + 4
+	overflowBase := baseOffset + uint32(ifdSize)
+
+	binary.Write(&ifd, order, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(&ifd, order, e.tag)
+		binary.Write(&ifd, order, e.typ)
+		binary.Write(&ifd, order, e.count)
+		if len(e.value) <= 4 {
+			padded := make([]byte, 4)
+			copy(padded, e.value)
+			ifd.Write(padded)
+		} else {
+			var offsetBytes [4]byte
+			order.PutUint32(offsetBytes[:], overflowBase+uint32(overflow.Len()))
+			ifd.Write(offsetBytes[:])
+			overflow.Write(e.value)
+			if overflow.Len()%2 != 0 {
+				overflow.WriteByte(0)
+			}
+		}
+	}
+	binary.Write(&ifd, order, uint32(0)) // no next IFD
+	ifd.Write(overflow.Bytes())
+
+	return ifd.Bytes()
+}
+
+func uint32Bytes(order binary.ByteOrder, v uint32) []byte {
+	b := make([]byte, 4)
+	order.PutUint32(b, v)
+	return b
+}
+
+func uint16Bytes(order binary.ByteOrder, v uint16) []byte {
+	b := make([]byte, 4)
+	order.PutUint16(b, v)
+	return b
+}
+
+// buildMinimalEXIF writes a little-endian TIFF structure containing just
+// Orientation (reset to 1, since the pixels have already been rotated
+// upright), DateTimeOriginal and GPSLatitude/GPSLongitude when present in
+// metadata, each in their own Exif/GPS sub-IFD as ParseEXIF expects. It's
+// deliberately small: just enough to keep provenance on a re-encoded tile,
+// not a general-purpose EXIF writer.
+func buildMinimalEXIF(metadata *Metadata) []byte {
+	order := binary.LittleEndian
+	byteOrder := binary.ByteOrder(order)
+
+	const ifd0Offset = 8
+
+	ifd0Entries := []rawEntry{
+		{tag: tagOrientation, typ: ifdTypeShort, count: 1, value: uint16Bytes(byteOrder, 1)},
+	}
+
+	var exifEntries []rawEntry
+	if !metadata.DateTimeOriginal.IsZero() {
+		dt := append([]byte(metadata.DateTimeOriginal.UTC().Format(exifDateLayout)), 0)
+		exifEntries = append(exifEntries, rawEntry{tag: tagDateTimeOriginal, typ: ifdTypeASCII, count: uint32(len(dt)), value: dt})
+	}
+
+	var gpsEntries []rawEntry
+	if metadata.HasGPS {
+		lat, latRef := gpsRational(metadata.GPSLatitude, 'N', 'S')
+		lon, lonRef := gpsRational(metadata.GPSLongitude, 'E', 'W')
+		gpsEntries = []rawEntry{
+			{tag: tagGPSLatitudeRef, typ: ifdTypeASCII, count: 2, value: []byte{latRef, 0}},
+			{tag: tagGPSLatitude, typ: ifdTypeRational, count: 3, value: lat},
+			{tag: tagGPSLongitudeRef, typ: ifdTypeASCII, count: 2, value: []byte{lonRef, 0}},
+			{tag: tagGPSLongitude, typ: ifdTypeRational, count: 3, value: lon},
+		}
+	}
+
+	// IFD0's pointer entries are LONG (always inline), so its own size
+	// doesn't depend on where the sub-IFDs end up; only their own
+	// contents do. Reserve the pointer entries now and patch their
+	// values once the sub-IFDs' offsets are known.
+	exifPointerIdx, gpsPointerIdx := -1, -1
+	if len(exifEntries) > 0 {
+		exifPointerIdx = len(ifd0Entries)
+		ifd0Entries = append(ifd0Entries, rawEntry{tag: tagExifIFDPointer, typ: ifdTypeLong, count: 1, value: uint32Bytes(byteOrder, 0)})
+	}
+	if len(gpsEntries) > 0 {
+		gpsPointerIdx = len(ifd0Entries)
+		ifd0Entries = append(ifd0Entries, rawEntry{tag: tagGPSInfoPointer, typ: ifdTypeLong, count: 1, value: uint32Bytes(byteOrder, 0)})
+	}
+
+	ifd0Size := 2 + 12*len(ifd0Entries) + 4
+	exifOffset := uint32(ifd0Offset + ifd0Size)
+
+	var exifIFD []byte
+	gpsBase := exifOffset
+	if len(exifEntries) > 0 {
+		exifIFD = encodeIFD(byteOrder, exifEntries, exifOffset)
+		ifd0Entries[exifPointerIdx].value = uint32Bytes(byteOrder, exifOffset)
+		gpsBase = exifOffset + uint32(len(exifIFD))
+	}
+
+	var gpsIFD []byte
+	if len(gpsEntries) > 0 {
+		gpsIFD = encodeIFD(byteOrder, gpsEntries, gpsBase)
+		ifd0Entries[gpsPointerIdx].value = uint32Bytes(byteOrder, gpsBase)
+	}
+
+	ifd0 := encodeIFD(byteOrder, ifd0Entries, ifd0Offset)
+
+	var tiff bytes.Buffer
+	tiff.WriteString("II")
+	binary.Write(&tiff, order, uint16(42))
+	binary.Write(&tiff, order, uint32(ifd0Offset))
+	tiff.Write(ifd0)
+	tiff.Write(exifIFD)
+	tiff.Write(gpsIFD)
+
+	return tiff.Bytes()
+}
+
+// gpsRational encodes a signed decimal-degree coordinate as a 3-RATIONAL
+// degrees/minutes/seconds value (denominators of 1/1/1000 to keep seconds
+// to millisecond precision) plus its reference letter.
+func gpsRational(value float64, positiveRef, negativeRef byte) ([]byte, byte) {
+	ref := positiveRef
+	if value < 0 {
+		ref = negativeRef
+		value = -value
+	}
+
+	degrees := math.Floor(value)
+	minutesFloat := (value - degrees) * 60
+	minutes := math.Floor(minutesFloat)
+	seconds := (minutesFloat - minutes) * 60
+
+	buf := make([]byte, 24)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(degrees))
+	binary.LittleEndian.PutUint32(buf[4:8], 1)
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(minutes))
+	binary.LittleEndian.PutUint32(buf[12:16], 1)
+	binary.LittleEndian.PutUint32(buf[16:20], uint32(seconds*1000))
+	binary.LittleEndian.PutUint32(buf[20:24], 1000)
+	return buf, ref
+}
+
+// EmbedEXIF inserts an APP1 Exif segment built from metadata into a JPEG
+// byte stream, right after the SOI marker, reusing whatever orientation,
+// DateTimeOriginal and GPS fields metadata carries (with orientation reset
+// to 1, since jpegData's pixels are already upright).
+func EmbedEXIF(jpegData []byte, metadata *Metadata) []byte {
+	if metadata == nil || len(jpegData) < 2 {
+		return jpegData
+	}
+
+	tiff := buildMinimalEXIF(metadata)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	var out bytes.Buffer
+	out.Write(jpegData[:2]) // SOI
+	out.WriteByte(0xFF)
+	out.WriteByte(0xE1)
+	binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(jpegData[2:])
+	return out.Bytes()
+}
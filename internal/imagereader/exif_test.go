@@ -0,0 +1,81 @@
+package imagereader
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseEXIFRoundTrip builds a JPEG with a minimal Exif segment via
+// EmbedEXIF/buildMinimalEXIF (the same path encodeTile uses for
+// PreserveMetadata) and checks ParseEXIF recovers the fields that went in,
+// covering readIFD, resolveEntry and gpsCoordinate end to end.
+func TestParseEXIFRoundTrip(t *testing.T) {
+	want := &Metadata{
+		DateTimeOriginal: time.Date(2024, 3, 5, 14, 22, 10, 0, time.UTC),
+		HasGPS:           true,
+		GPSLatitude:      49.2827,
+		GPSLongitude:     -123.1207,
+	}
+
+	jpeg := EmbedEXIF([]byte{0xFF, 0xD8, 0xFF, 0xD9}, want)
+
+	got, err := ParseEXIF(jpeg)
+	if err != nil {
+		t.Fatalf("ParseEXIF: %v", err)
+	}
+	if got == nil {
+		t.Fatal("ParseEXIF: got nil Metadata")
+	}
+
+	// buildMinimalEXIF always resets Orientation to 1, since the pixels
+	// it's attached to are already upright.
+	if got.Orientation != 1 {
+		t.Errorf("Orientation = %d, want 1", got.Orientation)
+	}
+	if !got.DateTimeOriginal.Equal(want.DateTimeOriginal) {
+		t.Errorf("DateTimeOriginal = %v, want %v", got.DateTimeOriginal, want.DateTimeOriginal)
+	}
+	if !got.HasGPS {
+		t.Fatal("HasGPS = false, want true")
+	}
+	const tolerance = 0.001 // gpsRational keeps millisecond-of-arc precision
+	if diff := got.GPSLatitude - want.GPSLatitude; diff < -tolerance || diff > tolerance {
+		t.Errorf("GPSLatitude = %v, want %v", got.GPSLatitude, want.GPSLatitude)
+	}
+	if diff := got.GPSLongitude - want.GPSLongitude; diff < -tolerance || diff > tolerance {
+		t.Errorf("GPSLongitude = %v, want %v", got.GPSLongitude, want.GPSLongitude)
+	}
+}
+
+// TestParseEXIFNoSegment checks that data without an Exif APP1 segment (a
+// bare JPEG, or something that isn't a JPEG at all) reports no error and no
+// Metadata, since that's the common case for PNG/WebP/AVIF and stripped
+// JPEGs.
+func TestParseEXIFNoSegment(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"plain JPEG": {0xFF, 0xD8, 0xFF, 0xD9},
+		"not a JPEG": {0x89, 'P', 'N', 'G'},
+		"truncated":  {0xFF, 0xD8},
+		"empty":      {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			md, err := ParseEXIF(data)
+			if err != nil {
+				t.Fatalf("ParseEXIF: unexpected error: %v", err)
+			}
+			if md != nil {
+				t.Fatalf("ParseEXIF: got %+v, want nil", md)
+			}
+		})
+	}
+}
+
+// TestGPSCoordinateMissingTag checks that gpsCoordinate reports !ok for a
+// GPS IFD holding neither the coordinate nor its reference tag, rather than
+// returning a zero value that looks like a real (0, 0) coordinate.
+func TestGPSCoordinateMissingTag(t *testing.T) {
+	_, ok := gpsCoordinate(map[uint16]resolvedEntry{}, nil, nil, tagGPSLatitude, tagGPSLatitudeRef, 'S')
+	if ok {
+		t.Fatal("gpsCoordinate: got ok=true for an empty IFD, want false")
+	}
+}
@@ -1,39 +1,65 @@
 package imagereader
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"io"
 
 	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
 	"github.com/gen2brain/avif"
 	"golang.org/x/image/tiff"
 )
 
-func Decode(reader io.Reader) (image.Image, string, error) {
+// Decode decodes reader into an image, also returning whatever EXIF
+// Metadata could be recovered (currently only from JPEG's APP1 segment).
+// metadata is nil when reader isn't a JPEG or carries no EXIF segment.
+func Decode(reader io.Reader) (img image.Image, format string, metadata *Metadata, err error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("imagereader: reading image data: %w", err)
+	}
+
+	img, format, err = decodeBytes(data)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	metadata, err = ParseEXIF(data)
+	if err != nil {
+		// A corrupt or unsupported Exif segment shouldn't fail decoding of
+		// an otherwise-valid image.
+		metadata = nil
+	}
+
+	return img, format, metadata, nil
+}
+
+func decodeBytes(data []byte) (image.Image, string, error) {
 	// Detect format using the standard image package
-	img, format, err := image.Decode(reader)
+	img, format, err := image.Decode(bytes.NewReader(data))
 	if err == nil {
 		return img, format, nil
 	}
 
-	img, err = jpeg.Decode(reader)
+	img, err = jpeg.Decode(bytes.NewReader(data))
 	if err == nil {
 		return img, "jpeg", nil
 	}
 
-	img, err = webp.Decode(reader)
+	img, err = webp.Decode(bytes.NewReader(data))
 	if err == nil {
 		return img, "webp", nil
 	}
 
-	img, err = avif.Decode(reader)
+	img, err = avif.Decode(bytes.NewReader(data))
 	if err == nil {
 		return img, "avif", nil
 	}
 
-	img, err = tiff.Decode(reader)
+	img, err = tiff.Decode(bytes.NewReader(data))
 	if err == nil {
 		return img, "tiff", nil
 	}
@@ -41,3 +67,76 @@ func Decode(reader io.Reader) (image.Image, string, error) {
 	// If no decoder could handle the data, return an error
 	return nil, "", fmt.Errorf("unsupported image format or corrupted image")
 }
+
+// DecodeConfig reads just enough of reader to learn the image's dimensions
+// and format, without decoding any pixel data. It peeks the header through a
+// growing buffer so that, like Decode, it can fall back through every
+// supported format without having to re-read reader from the start.
+func DecodeConfig(reader io.Reader) (image.Config, string, error) {
+	var header bytes.Buffer
+
+	// tryConfig replays everything read so far followed by the rest of
+	// reader, and records any newly consumed bytes back into header so the
+	// next format attempt (on failure) can replay from the start again.
+	tryConfig := func(decode func(io.Reader) (image.Config, string, error)) (image.Config, string, error) {
+		tail := new(bytes.Buffer)
+		src := io.MultiReader(bytes.NewReader(header.Bytes()), io.TeeReader(reader, tail))
+		cfg, format, err := decode(src)
+		header.Write(tail.Bytes())
+		return cfg, format, err
+	}
+
+	if cfg, format, err := tryConfig(func(r io.Reader) (image.Config, string, error) {
+		return image.DecodeConfig(r)
+	}); err == nil {
+		return cfg, format, nil
+	}
+
+	formats := []struct {
+		name   string
+		decode func(io.Reader) (image.Config, error)
+	}{
+		{"webp", webp.DecodeConfig},
+		{"avif", avif.DecodeConfig},
+		{"tiff", tiff.DecodeConfig},
+	}
+	for _, f := range formats {
+		if cfg, _, err := tryConfig(func(r io.Reader) (image.Config, string, error) {
+			cfg, err := f.decode(r)
+			return cfg, f.name, err
+		}); err == nil {
+			return cfg, f.name, nil
+		}
+	}
+
+	return image.Config{}, "", fmt.Errorf("unsupported image format or corrupted image")
+}
+
+// DecodeScaled decodes reader into an image no larger than maxW x maxH,
+// also returning whatever EXIF Metadata Decode recovered. None of Go's
+// standard decoders for the formats this package supports (including
+// JPEG, unlike libjpeg) expose a scaled decode path, so this always does
+// a full Decode followed by a Lanczos resize down to the requested
+// bounds - it only reduces the size of the image a caller holds on to
+// afterwards, not the peak memory used while decoding. Callers that need
+// to avoid decoding an oversized image at all should gate on
+// DecodeConfig's dimensions first (see imagetiler.Tiler's
+// MemoryBudgetBytes). Passing maxW <= 0 or maxH <= 0 disables
+// downscaling and returns the image at its native size.
+func DecodeScaled(reader io.Reader, maxW, maxH int) (image.Image, string, *Metadata, error) {
+	img, format, metadata, err := Decode(reader)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if maxW <= 0 || maxH <= 0 {
+		return img, format, metadata, nil
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() <= maxW && bounds.Dy() <= maxH {
+		return img, format, metadata, nil
+	}
+
+	return imaging.Fit(img, maxW, maxH, imaging.Lanczos), format, metadata, nil
+}
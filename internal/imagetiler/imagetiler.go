@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/jpeg"
 	"image/png"
 	"math"
 	"os"
@@ -13,15 +14,25 @@ import (
 	"log/slog"
 
 	"github.com/disintegration/imaging"
+
+	"github.com/StrongerSoftworks/image-tagger/internal/imagereader"
+	"github.com/StrongerSoftworks/image-tagger/internal/preproc"
 )
 
 type Mode string
 
 const (
-	ModeFit  Mode = "fit"
-	ModeTile Mode = "tile"
+	ModeFit   Mode = "fit"
+	ModeTile  Mode = "tile"
+	ModeSmart Mode = "smart"
 )
 
+// ScoreFunc computes a per-pixel "interest" score for an image, used by
+// ModeSmart to decide which regions are worth cropping. Higher scores mean
+// more interesting content. The returned slice is indexed [y][x] and must
+// match the dimensions of img.Bounds().
+type ScoreFunc func(img image.Image) [][]float64
+
 type Options struct {
 	MaxCrops    int
 	CropSize    int
@@ -31,9 +42,122 @@ type Options struct {
 	Width       int
 	Height      int
 	Mode        Mode
+
+	// SmartCropStride is the step size, in pixels, used to slide the
+	// candidate crop window over the image when Mode is ModeSmart.
+	// Defaults to CropSize/4 when unset.
+	SmartCropStride int
+	// SmartCropMinScore discards candidate windows whose average
+	// interest score falls below this value. 0 disables the filter.
+	SmartCropMinScore float64
+	// ScoreFunc optionally overrides the default interest scorer used by
+	// ModeSmart. When nil, a gradient-magnitude/saturation scorer is used.
+	ScoreFunc ScoreFunc
+	// UseSaliency blends a spectral-residual saliency map into the default
+	// interest scorer when Mode is ModeSmart and ScoreFunc is unset,
+	// favoring globally salient regions over purely locally textured ones.
+	UseSaliency bool
+
+	// MemoryBudgetBytes, when set via NewTiler, rejects images whose
+	// decoded pixel footprint (width * height * 4 bytes) would exceed the
+	// budget, returning ErrImageTooLarge instead of decoding them. 0
+	// disables the check.
+	MemoryBudgetBytes int64
+
+	// DedupHammingThreshold drops tiles (other than tile 0, the full
+	// resized image, which is always kept) whose perceptual hash is
+	// less than this Hamming distance from an already-kept tile. 0 disables
+	// deduplication.
+	DedupHammingThreshold int
+
+	// Metadata is the EXIF metadata recovered from the source image, if
+	// any. When set, its Orientation is applied to upright the image
+	// before resizing/cropping.
+	Metadata *imagereader.Metadata
+	// Preproc, when non-empty, cleans up the image (grayscale,
+	// binarization, deskew, border wipe) after EXIF orientation is
+	// applied but before resizing/cropping, so steps like deskew/wipe
+	// that scan along the image's axes see an upright image. A
+	// zero-value Pipeline is a no-op.
+	Preproc preproc.Pipeline
+	// PreserveMetadata, when true, encodes tile 0 as JPEG instead of PNG
+	// and re-embeds a minimal Exif block (orientation reset to 1, plus
+	// DateTimeOriginal/GPS from Metadata if present) so downstream
+	// consumers keep provenance.
+	PreserveMetadata bool
 }
 
 func MakeImageTiles(options Options, img image.Image) [][]byte {
+	croppedImages, _ := computeCrops(options, img)
+
+	imageData := make([][]byte, len(croppedImages))
+	for i, cropped := range croppedImages {
+		if options.SaveCropped {
+			saveCroppedImage(cropped, options.ImagePath, options.OutputDir, i)
+		}
+
+		encoded, err := encodeTile(cropped, i, options)
+		if err != nil {
+			slog.Error("Error encoding image", "error", err)
+			return nil
+		}
+		imageData[i] = encoded
+	}
+	return imageData
+}
+
+// encodeTile encodes a single tile as PNG, except for tile 0 when
+// PreserveMetadata is set, which is encoded as JPEG with a re-embedded
+// Exif block so the output keeps the source's provenance.
+func encodeTile(img image.Image, index int, options Options) ([]byte, error) {
+	if options.PreserveMetadata && index == 0 && options.Metadata != nil {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, img, nil); err != nil {
+			return nil, err
+		}
+		return imagereader.EmbedEXIF(buf.Bytes(), options.Metadata), nil
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// applyOrientation returns img rotated/flipped upright per metadata's EXIF
+// Orientation tag (1-8), following the standard flip-then-rotate
+// combinations for orientations 5-8.
+func applyOrientation(img image.Image, metadata *imagereader.Metadata) image.Image {
+	switch metadata.Orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Rotate90(imaging.FlipH(img))
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Rotate270(imaging.FlipH(img))
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
+
+// computeCrops resizes img per options and, for tile/smart modes, selects
+// the individual crop regions. The first element is always the full
+// resized image.
+func computeCrops(options Options, img image.Image) ([]image.Image, []uint64) {
+	if options.Metadata != nil {
+		img = applyOrientation(img, options.Metadata)
+	}
+	img = options.Preproc.Apply(img)
+
 	bounds := img.Bounds()
 	imgWidth := bounds.Dx()
 	imgHeight := bounds.Dy()
@@ -43,42 +167,24 @@ func MakeImageTiles(options Options, img image.Image) [][]byte {
 
 	// crop mode
 	if options.Mode == ModeFit {
-		buf := new(bytes.Buffer)
-		err := png.Encode(buf, resizedImage)
-		if err != nil {
-			slog.Error("Error encoding image", "error", err)
-			return nil
-		}
-		return [][]byte{buf.Bytes()}
+		return []image.Image{resizedImage}, []uint64{phash(resizedImage)}
 	}
 
-	// tile mode
+	// tile/smart mode
 	maxSize := int(float64(options.CropSize) * math.Floor(math.Sqrt(float64(options.MaxCrops))) * 1.5)
 	slog.Debug("Resizing image", "from", fmt.Sprintf("%d x %d", imgWidth, imgHeight), "to", fmt.Sprintf("%d x %d", maxSize, maxSize))
 	img = imaging.Fit(img, maxSize, maxSize, imaging.Lanczos)
-	croppedImages := cropImage(img, options.Width, options.Height)
-
-	// always include the resized full image as the first image
-	croppedImages = append([]image.Image{resizedImage}, croppedImages...)
-
-	var imageData [][]byte = make([][]byte, len(croppedImages)+1)
-	// Save or process the cropped images
-	for i, cropped := range croppedImages {
-		if options.SaveCropped {
-			saveCroppedImage(cropped, options.ImagePath, options.OutputDir, i)
-		}
-
-		buf := new(bytes.Buffer)
-		err := png.Encode(buf, cropped)
-		if err != nil {
-			slog.Error("Error encoding image", "error", err)
-			return nil
-		}
-		imageData[i] = buf.Bytes()
 
+	var croppedImages []image.Image
+	if options.Mode == ModeSmart {
+		croppedImages = smartCropImage(img, options)
+	} else {
+		croppedImages = cropImage(img, options.Width, options.Height)
 	}
-	return imageData
 
+	// always include the resized full image as the first image
+	croppedImages = append([]image.Image{resizedImage}, croppedImages...)
+	return dedupeByPHash(croppedImages, options.DedupHammingThreshold)
 }
 
 func cropImage(img image.Image, cropWidth, cropHeight int) []image.Image {
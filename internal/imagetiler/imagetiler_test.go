@@ -0,0 +1,74 @@
+package imagetiler
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/StrongerSoftworks/image-tagger/internal/imagereader"
+)
+
+// asymmetricFixture returns a small image with a distinct color in every
+// pixel, so any rotation or flip produces a uniquely identifiable result.
+func asymmetricFixture() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	colors := [][]color.NRGBA{
+		{{R: 255, A: 255}, {G: 255, A: 255}, {B: 255, A: 255}},
+		{{R: 255, G: 255, A: 255}, {G: 255, B: 255, A: 255}, {R: 255, B: 255, A: 255}},
+	}
+	for y, row := range colors {
+		for x, c := range row {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func imagesEqual(a, b image.Image) bool {
+	if a.Bounds().Dx() != b.Bounds().Dx() || a.Bounds().Dy() != b.Bounds().Dy() {
+		return false
+	}
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, aa := a.At(x, y).RGBA()
+			br, bg, bb, ba := b.At(bounds.Min.X+(x-bounds.Min.X), bounds.Min.Y+(y-bounds.Min.Y)).RGBA()
+			if ar != br || ag != bg || ab != bb || aa != ba {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TestApplyOrientation checks every EXIF orientation code against the
+// canonical flip/rotate transform it's documented to produce, using
+// disintegration/imaging's own named transforms (Transpose, Transverse,
+// etc.) as the source of truth. This is the table a lookup-table mixup
+// like swapped 5/7 cases should show up in immediately.
+func TestApplyOrientation(t *testing.T) {
+	src := asymmetricFixture()
+
+	cases := []struct {
+		orientation int
+		want        image.Image
+	}{
+		{1, src},
+		{2, imaging.FlipH(src)},
+		{3, imaging.Rotate180(src)},
+		{4, imaging.FlipV(src)},
+		{5, imaging.Transpose(src)},
+		{6, imaging.Rotate270(src)},
+		{7, imaging.Transverse(src)},
+		{8, imaging.Rotate90(src)},
+	}
+
+	for _, c := range cases {
+		got := applyOrientation(src, &imagereader.Metadata{Orientation: c.orientation})
+		if !imagesEqual(got, c.want) {
+			t.Errorf("orientation %d: got pixels differ from expected transform", c.orientation)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package imagetiler
+
+import (
+	"image"
+	"math"
+	"math/bits"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	phashSize    = 32
+	phashLowFreq = 8
+)
+
+// phash computes a 64-bit perceptual hash of img using the classic
+// average-DCT pipeline: downscale to a small grayscale square, take a 2D
+// DCT-II, keep the low-frequency 8x8 block (excluding the DC coefficient),
+// and set one bit per coefficient based on whether it's above the block's
+// mean.
+func phash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, phashSize, phashSize, imaging.Lanczos))
+
+	pixels := make([][]float64, phashSize)
+	for y := 0; y < phashSize; y++ {
+		pixels[y] = make([]float64, phashSize)
+		for x := 0; x < phashSize; x++ {
+			r, _, _, _ := small.At(x, y).RGBA()
+			pixels[y][x] = float64(r >> 8)
+		}
+	}
+
+	dct := dct2D(pixels)
+
+	// Flatten the low-frequency 8x8 block, skipping the DC term at [0][0].
+	coeffs := make([]float64, 0, phashLowFreq*phashLowFreq-1)
+	for y := 0; y < phashLowFreq; y++ {
+		for x := 0; x < phashLowFreq; x++ {
+			if x == 0 && y == 0 {
+				continue
+			}
+			coeffs = append(coeffs, dct[y][x])
+		}
+	}
+
+	mean := 0.0
+	for _, c := range coeffs {
+		mean += c
+	}
+	mean /= float64(len(coeffs))
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > mean {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// dct2D computes the 2D DCT-II of an NxN matrix.
+func dct2D(pixels [][]float64) [][]float64 {
+	n := len(pixels)
+	out := make([][]float64, n)
+	for i := range out {
+		out[i] = make([]float64, n)
+	}
+
+	alpha := func(u int) float64 {
+		if u == 0 {
+			return 1 / math.Sqrt2
+		}
+		return 1
+	}
+
+	for v := 0; v < n; v++ {
+		for u := 0; u < n; u++ {
+			sum := 0.0
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					sum += pixels[y][x] *
+						math.Cos((2*float64(x)+1)*float64(u)*math.Pi/(2*float64(n))) *
+						math.Cos((2*float64(y)+1)*float64(v)*math.Pi/(2*float64(n)))
+				}
+			}
+			out[v][u] = 0.25 * alpha(u) * alpha(v) * sum
+		}
+	}
+	return out
+}
+
+// dedupeByPHash drops any image (other than the first, which is always the
+// full resized image and is kept unconditionally) whose perceptual hash is
+// less than threshold Hamming distance from an already-kept image. It returns
+// the surviving images alongside the hash computed for each of them.
+func dedupeByPHash(images []image.Image, threshold int) ([]image.Image, []uint64) {
+	if threshold <= 0 || len(images) == 0 {
+		hashes := make([]uint64, len(images))
+		for i, img := range images {
+			hashes[i] = phash(img)
+		}
+		return images, hashes
+	}
+
+	kept := make([]image.Image, 0, len(images))
+	hashes := make([]uint64, 0, len(images))
+
+	for i, img := range images {
+		h := phash(img)
+		if i == 0 {
+			kept = append(kept, img)
+			hashes = append(hashes, h)
+			continue
+		}
+
+		duplicate := false
+		for _, keptHash := range hashes {
+			if bits.OnesCount64(h^keptHash) < threshold {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		kept = append(kept, img)
+		hashes = append(hashes, h)
+	}
+	return kept, hashes
+}
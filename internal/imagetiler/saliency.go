@@ -0,0 +1,247 @@
+package imagetiler
+
+import (
+	"image"
+	"math"
+	"math/cmplx"
+)
+
+// saliencyMapSize is the side length of the downscaled copy spectral
+// residual saliency is computed on. Spectral residual saliency is a
+// global, low-frequency signal, so a small map is enough and keeps the
+// O(n^3) separable 2D DFT below cheap.
+const saliencyMapSize = 64
+
+// spectralResidualSaliency computes a saliency map for img using the
+// spectral residual method (Hou & Zhang, 2007): the log amplitude
+// spectrum of a downscaled grayscale copy, minus its local average, is
+// treated as the "residual" novel/salient frequency content; transforming
+// that residual back to the spatial domain (keeping the original phase)
+// yields a map that lights up on perceptually salient regions without
+// needing any learned model. The result is upscaled (nearest-neighbor)
+// back to img's original dimensions and normalized to [0, 1].
+func spectralResidualSaliency(img image.Image) [][]float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	small := downsampleGray(img, saliencyMapSize)
+	smallHeight := len(small)
+	smallWidth := len(small[0])
+
+	freq := forwardDFT2D(small)
+
+	logAmp := make([][]float64, smallHeight)
+	phase := make([][]float64, smallHeight)
+	for y := 0; y < smallHeight; y++ {
+		logAmp[y] = make([]float64, smallWidth)
+		phase[y] = make([]float64, smallWidth)
+		for x := 0; x < smallWidth; x++ {
+			amp := cmplx.Abs(freq[y][x])
+			if amp == 0 {
+				amp = 1e-12
+			}
+			logAmp[y][x] = math.Log(amp)
+			phase[y][x] = cmplx.Phase(freq[y][x])
+		}
+	}
+
+	avgLogAmp := boxBlur(logAmp, 1)
+
+	residual := make([][]complex128, smallHeight)
+	for y := 0; y < smallHeight; y++ {
+		residual[y] = make([]complex128, smallWidth)
+		for x := 0; x < smallWidth; x++ {
+			r := logAmp[y][x] - avgLogAmp[y][x]
+			residual[y][x] = cmplx.Rect(math.Exp(r), phase[y][x])
+		}
+	}
+
+	spatial := inverseDFT2D(residual)
+
+	saliency := make([][]float64, smallHeight)
+	maxVal := 0.0
+	for y := 0; y < smallHeight; y++ {
+		saliency[y] = make([]float64, smallWidth)
+		for x := 0; x < smallWidth; x++ {
+			mag := cmplx.Abs(spatial[y][x])
+			v := mag * mag
+			saliency[y][x] = v
+			if v > maxVal {
+				maxVal = v
+			}
+		}
+	}
+	saliency = boxBlur(saliency, 2)
+
+	// Re-derive the max after smoothing for normalization.
+	maxVal = 0
+	for y := 0; y < smallHeight; y++ {
+		for x := 0; x < smallWidth; x++ {
+			if saliency[y][x] > maxVal {
+				maxVal = saliency[y][x]
+			}
+		}
+	}
+	if maxVal == 0 {
+		maxVal = 1
+	}
+
+	return upsampleNearest(saliency, width, height, maxVal)
+}
+
+// downsampleGray converts img to grayscale and box-downsamples it to a
+// size x size grid (size on the longer side, letterboxed on the shorter
+// one by clamping source coordinates).
+func downsampleGray(img image.Image, size int) [][]float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	out := make([][]float64, size)
+	for y := 0; y < size; y++ {
+		out[y] = make([]float64, size)
+		srcY := bounds.Min.Y + y*height/size
+		for x := 0; x < size; x++ {
+			srcX := bounds.Min.X + x*width/size
+			r, g, b, _ := img.At(srcX, srcY).RGBA()
+			out[y][x] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+		}
+	}
+	return out
+}
+
+// upsampleNearest scales a small x small saliency map up to width x height
+// via nearest-neighbor, dividing by maxVal to normalize into [0, 1].
+func upsampleNearest(values [][]float64, width, height int, maxVal float64) [][]float64 {
+	size := len(values)
+	out := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		out[y] = make([]float64, width)
+		srcY := min(y*size/height, size-1)
+		for x := 0; x < width; x++ {
+			srcX := min(x*size/width, size-1)
+			out[y][x] = values[srcY][srcX] / maxVal
+		}
+	}
+	return out
+}
+
+// boxBlur averages each cell with its (2*radius+1)^2 neighborhood,
+// clamping at the edges.
+func boxBlur(values [][]float64, radius int) [][]float64 {
+	height := len(values)
+	if height == 0 {
+		return values
+	}
+	width := len(values[0])
+
+	out := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		out[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			sum := 0.0
+			count := 0
+			for dy := -radius; dy <= radius; dy++ {
+				py := clampInt(y+dy, 0, height-1)
+				for dx := -radius; dx <= radius; dx++ {
+					px := clampInt(x+dx, 0, width-1)
+					sum += values[py][px]
+					count++
+				}
+			}
+			out[y][x] = sum / float64(count)
+		}
+	}
+	return out
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// forwardDFT2D computes the 2D discrete Fourier transform of a real
+// grid via two separable 1D passes (rows, then columns).
+func forwardDFT2D(values [][]float64) [][]complex128 {
+	height := len(values)
+	width := len(values[0])
+
+	rows := make([][]complex128, height)
+	for y := 0; y < height; y++ {
+		row := make([]complex128, width)
+		for x := 0; x < width; x++ {
+			row[x] = complex(values[y][x], 0)
+		}
+		rows[y] = dft1D(row, false)
+	}
+
+	return transformColumns(rows, false)
+}
+
+// inverseDFT2D computes the 2D inverse discrete Fourier transform via two
+// separable 1D passes.
+func inverseDFT2D(freq [][]complex128) [][]complex128 {
+	height := len(freq)
+
+	rows := make([][]complex128, height)
+	for y := 0; y < height; y++ {
+		rows[y] = dft1D(freq[y], true)
+	}
+
+	return transformColumns(rows, true)
+}
+
+// transformColumns applies a 1D DFT (or inverse, when inverse is true) to
+// every column of rows.
+func transformColumns(rows [][]complex128, inverse bool) [][]complex128 {
+	height := len(rows)
+	width := len(rows[0])
+
+	out := make([][]complex128, height)
+	for y := range out {
+		out[y] = make([]complex128, width)
+	}
+
+	col := make([]complex128, height)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			col[y] = rows[y][x]
+		}
+		transformed := dft1D(col, inverse)
+		for y := 0; y < height; y++ {
+			out[y][x] = transformed[y]
+		}
+	}
+	return out
+}
+
+// dft1D computes the discrete Fourier transform of x (or its inverse,
+// normalized by 1/N, when inverse is true) using the direct O(n^2)
+// definition. The saliency maps this feeds are small (saliencyMapSize on
+// a side), so this is cheap enough without an FFT.
+func dft1D(x []complex128, inverse bool) []complex128 {
+	n := len(x)
+	out := make([]complex128, n)
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+
+	for k := 0; k < n; k++ {
+		var sum complex128
+		for t := 0; t < n; t++ {
+			angle := sign * 2 * math.Pi * float64(k) * float64(t) / float64(n)
+			sum += x[t] * cmplx.Rect(1, angle)
+		}
+		if inverse {
+			sum /= complex(float64(n), 0)
+		}
+		out[k] = sum
+	}
+	return out
+}
@@ -0,0 +1,279 @@
+package imagetiler
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"log/slog"
+)
+
+// integralImage is a summed-area table that allows the sum of any
+// axis-aligned rectangle of the source values to be computed in O(1).
+type integralImage struct {
+	sums  []float64
+	width int
+}
+
+func buildIntegralImage(values [][]float64) *integralImage {
+	height := len(values)
+	if height == 0 {
+		return &integralImage{}
+	}
+	width := len(values[0])
+
+	// sums is (width+1) x (height+1) so row/col 0 are the zero border.
+	sums := make([]float64, (width+1)*(height+1))
+	stride := width + 1
+	for y := 0; y < height; y++ {
+		rowSum := 0.0
+		for x := 0; x < width; x++ {
+			rowSum += values[y][x]
+			sums[(y+1)*stride+(x+1)] = sums[y*stride+(x+1)] + rowSum
+		}
+	}
+	return &integralImage{sums: sums, width: width}
+}
+
+// rectSum returns the sum of values within rect using the summed-area table.
+func (ii *integralImage) rectSum(rect image.Rectangle) float64 {
+	stride := ii.width + 1
+	x0, y0, x1, y1 := rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y
+	return ii.sums[y1*stride+x1] - ii.sums[y0*stride+x1] - ii.sums[y1*stride+x0] + ii.sums[y0*stride+x0]
+}
+
+// defaultScoreFunc computes a per-pixel interest score by blending Sobel
+// gradient magnitude with a saturation term, so both edge-dense regions and
+// vivid color regions score highly.
+func defaultScoreFunc(img image.Image) [][]float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	gray := make([][]float64, height)
+	sat := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		gray[y] = make([]float64, width)
+		sat[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			rf, gf, bf := float64(r>>8), float64(g>>8), float64(b>>8)
+			gray[y][x] = 0.299*rf + 0.587*gf + 0.114*bf
+
+			maxC := math.Max(rf, math.Max(gf, bf))
+			minC := math.Min(rf, math.Min(gf, bf))
+			if maxC > 0 {
+				sat[y][x] = (maxC - minC) / maxC
+			}
+		}
+	}
+
+	scores := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		scores[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			gx := sobelAt(gray, x, y, width, height, true)
+			gy := sobelAt(gray, x, y, width, height, false)
+			magnitude := math.Hypot(gx, gy)
+			scores[y][x] = magnitude + 64*sat[y][x]
+		}
+	}
+	return scores
+}
+
+// saliencyScoreFunc blends defaultScoreFunc's Sobel/saturation score with a
+// spectral-residual saliency map, so globally salient regions (e.g. a
+// subject against a busy but low-saliency background) can outscore purely
+// locally textured ones.
+func saliencyScoreFunc(img image.Image) [][]float64 {
+	scores := defaultScoreFunc(img)
+	saliency := spectralResidualSaliency(img)
+
+	for y := range scores {
+		for x := range scores[y] {
+			scores[y][x] *= 1 + saliency[y][x]
+		}
+	}
+	return scores
+}
+
+// sobelAt returns the horizontal (horizontal=true) or vertical Sobel
+// derivative at (x, y), clamping to the image edges.
+func sobelAt(gray [][]float64, x, y, width, height int, horizontal bool) float64 {
+	at := func(px, py int) float64 {
+		if px < 0 {
+			px = 0
+		} else if px >= width {
+			px = width - 1
+		}
+		if py < 0 {
+			py = 0
+		} else if py >= height {
+			py = height - 1
+		}
+		return gray[py][px]
+	}
+
+	if horizontal {
+		return (at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1)) -
+			(at(x-1, y-1) + 2*at(x-1, y) + at(x-1, y+1))
+	}
+	return (at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1)) -
+		(at(x-1, y-1) + 2*at(x, y-1) + at(x+1, y-1))
+}
+
+// iou returns the intersection-over-union of two rectangles.
+func iou(a, b image.Rectangle) float64 {
+	intersection := a.Intersect(b)
+	if intersection.Empty() {
+		return 0
+	}
+	intersectionArea := float64(intersection.Dx() * intersection.Dy())
+	unionArea := float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - intersectionArea
+	if unionArea <= 0 {
+		return 0
+	}
+	return intersectionArea / unionArea
+}
+
+const smartCropMaxIoU = 0.0
+
+// saveEnergyMap writes the interest score map scoreFunc produced as a
+// grayscale PNG ("<base>-energy.png" in outputDir) so users can debug why
+// ModeSmart picked the crops it did. Errors are logged, not returned,
+// matching saveCroppedImage's best-effort debugging behavior.
+func saveEnergyMap(scores [][]float64, originalPath, outputDir string) {
+	height := len(scores)
+	if height == 0 {
+		return
+	}
+	width := len(scores[0])
+
+	maxVal := 0.0
+	for _, row := range scores {
+		for _, v := range row {
+			if v > maxVal {
+				maxVal = v
+			}
+		}
+	}
+	if maxVal == 0 {
+		maxVal = 1
+	}
+
+	energy := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			energy.Set(x, y, color.Gray{Y: uint8(255 * scores[y][x] / maxVal)})
+		}
+	}
+
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		slog.Error("Error creating output dir", "error", err)
+		return
+	}
+
+	ext := filepath.Ext(originalPath)
+	base := strings.TrimSuffix(filepath.Base(originalPath), ext)
+	file, err := os.Create(fmt.Sprintf("%s/%s-energy.png", outputDir, base))
+	if err != nil {
+		slog.Error("Error saving energy map", "error", err)
+		return
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, energy); err != nil {
+		slog.Error("Error encoding energy map", "error", err)
+	}
+}
+
+// smartCropImage selects up to options.MaxCrops-1 content-aware crops
+// (tile 0, the full resized image, is always added separately by the
+// caller) by scoring every CropSize x CropSize window on a summed-area
+// table of an interest map and greedily keeping the highest scoring
+// non-overlapping windows.
+func smartCropImage(img image.Image, options Options) []image.Image {
+	bounds := img.Bounds()
+	imgWidth := bounds.Dx()
+	imgHeight := bounds.Dy()
+	cropWidth, cropHeight := options.CropSize, options.CropSize
+
+	if imgWidth <= cropWidth && imgHeight <= cropHeight {
+		return []image.Image{img}
+	}
+
+	scoreFunc := options.ScoreFunc
+	if scoreFunc == nil {
+		scoreFunc = defaultScoreFunc
+		if options.UseSaliency {
+			scoreFunc = saliencyScoreFunc
+		}
+	}
+	scores := scoreFunc(img)
+	if options.SaveCropped {
+		saveEnergyMap(scores, options.ImagePath, options.OutputDir)
+	}
+	integral := buildIntegralImage(scores)
+
+	stride := options.SmartCropStride
+	if stride <= 0 {
+		stride = max(1, cropWidth/4)
+	}
+
+	maxCrops := options.MaxCrops - 1
+	if maxCrops < 0 {
+		maxCrops = 0
+	}
+
+	type candidate struct {
+		rect  image.Rectangle
+		score float64
+	}
+
+	var candidates []candidate
+	for y := 0; y <= imgHeight-cropHeight; y += stride {
+		for x := 0; x <= imgWidth-cropWidth; x += stride {
+			rect := image.Rect(x, y, x+cropWidth, y+cropHeight)
+			area := float64(cropWidth * cropHeight)
+			score := integral.rectSum(rect) / area
+			if score < options.SmartCropMinScore {
+				continue
+			}
+			candidates = append(candidates, candidate{rect: rect, score: score})
+		}
+	}
+
+	// Greedy non-max suppression: repeatedly take the highest scoring
+	// remaining candidate and drop anything that overlaps it at all.
+	var selected []image.Rectangle
+	for len(candidates) > 0 && len(selected) < maxCrops {
+		bestIdx := 0
+		for i, c := range candidates {
+			if c.score > candidates[bestIdx].score {
+				bestIdx = i
+			}
+		}
+		best := candidates[bestIdx]
+		selected = append(selected, best.rect)
+
+		remaining := candidates[:0]
+		for _, c := range candidates {
+			if iou(c.rect, best.rect) <= smartCropMaxIoU {
+				remaining = append(remaining, c)
+			}
+		}
+		candidates = remaining
+	}
+
+	croppedImages := make([]image.Image, len(selected))
+	for i, rect := range selected {
+		croppedImages[i] = img.(interface {
+			SubImage(r image.Rectangle) image.Image
+		}).SubImage(rect)
+	}
+	return croppedImages
+}
@@ -0,0 +1,39 @@
+package imagetiler
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gradientFixture returns an image with enough local variation for
+// smartCropImage's default scorer to find distinct candidate windows.
+func gradientFixture(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: uint8((x + y) % 256), A: 255})
+		}
+	}
+	return img
+}
+
+// TestSmartCropImageMaxCropsOne checks that MaxCrops: 1 yields zero
+// additional crops, since smartCropImage only selects the crops added on
+// top of the full resized image (tile 0) the caller always prepends - a
+// previous off-by-one clamped maxCrops back up to 1 instead of 0, silently
+// returning 2 total tiles for a MaxCrops: 1 request.
+func TestSmartCropImageMaxCropsOne(t *testing.T) {
+	img := gradientFixture(64, 64)
+
+	crops := smartCropImage(img, Options{
+		MaxCrops: 1,
+		Width:    16,
+		Height:   16,
+		CropSize: 16,
+	})
+
+	if len(crops) != 0 {
+		t.Fatalf("got %d additional crops for MaxCrops: 1, want 0", len(crops))
+	}
+}
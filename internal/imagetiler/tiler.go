@@ -0,0 +1,153 @@
+package imagetiler
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+
+	"github.com/StrongerSoftworks/image-tagger/internal/imagereader"
+)
+
+// Tile is a single encoded crop produced by a Tiler, in the order it was
+// generated. Index 0 is always the full resized image.
+type Tile struct {
+	Index int
+	Bytes []byte
+	Rect  image.Rectangle
+	// Hash is the tile's perceptual hash, as used by
+	// Options.DedupHammingThreshold, so callers can persist or reuse it.
+	Hash uint64
+}
+
+// ErrImageTooLarge is returned (via Tiler.Err) when an image's decoded
+// pixel footprint would exceed Options.MemoryBudgetBytes.
+type ErrImageTooLarge struct {
+	Width, Height int
+	Footprint     int64
+	Budget        int64
+}
+
+func (e *ErrImageTooLarge) Error() string {
+	return fmt.Sprintf("image %dx%d would require ~%d decoded bytes, exceeding the %d byte memory budget", e.Width, e.Height, e.Footprint, e.Budget)
+}
+
+// Tiler produces the tiles for a single image without requiring the full
+// decoded image to be resident in memory for longer than it takes to
+// resize and crop it. Large source images are fully decoded and then
+// immediately downscaled, via imagereader.DecodeScaled, to a size close to
+// what MaxCrops/CropSize actually need; the decode itself still holds the
+// full-size image.
+type Tiler struct {
+	options  Options
+	err      error
+	metadata *imagereader.Metadata
+}
+
+// NewTiler creates a Tiler for options.ImagePath. The source file is opened
+// lazily when Tiles is called.
+func NewTiler(options Options) *Tiler {
+	return &Tiler{options: options}
+}
+
+// Err returns any error encountered while producing tiles, such as
+// ErrImageTooLarge. It should be checked after the channel returned by
+// Tiles is drained/closed.
+func (t *Tiler) Err() error {
+	return t.err
+}
+
+// Metadata returns the EXIF metadata recovered from the source image, if
+// any. It should be read after the channel returned by Tiles is
+// drained/closed.
+func (t *Tiler) Metadata() *imagereader.Metadata {
+	return t.metadata
+}
+
+// Tiles decodes and crops the image, emitting each encoded tile on the
+// returned channel as soon as it's ready so a caller can start acting on
+// tile 0 while the rest are still being produced. The channel is closed
+// once every tile has been sent, ctx is cancelled, or an error occurs (see
+// Err).
+func (t *Tiler) Tiles(ctx context.Context) <-chan Tile {
+	ch := make(chan Tile)
+
+	go func() {
+		defer close(ch)
+
+		file, err := os.Open(t.options.ImagePath)
+		if err != nil {
+			t.err = err
+			slog.Error("Error opening image", "error", err)
+			return
+		}
+		defer file.Close()
+
+		cfg, _, err := imagereader.DecodeConfig(file)
+		if err != nil {
+			t.err = err
+			slog.Error("Error reading image header", "error", err)
+			return
+		}
+
+		if t.options.MemoryBudgetBytes > 0 {
+			footprint := int64(cfg.Width) * int64(cfg.Height) * 4
+			if footprint > t.options.MemoryBudgetBytes {
+				t.err = &ErrImageTooLarge{
+					Width:     cfg.Width,
+					Height:    cfg.Height,
+					Footprint: footprint,
+					Budget:    t.options.MemoryBudgetBytes,
+				}
+				slog.Error("Image too large for memory budget", "error", t.err)
+				return
+			}
+		}
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			t.err = err
+			slog.Error("Error rewinding image", "error", err)
+			return
+		}
+
+		maxSize := int(float64(t.options.CropSize) * math.Floor(math.Sqrt(float64(t.options.MaxCrops))) * 1.5)
+		maxSize = max(maxSize, t.options.Width, t.options.Height)
+		img, _, metadata, err := imagereader.DecodeScaled(file, maxSize, maxSize)
+		if err != nil {
+			t.err = err
+			slog.Error("Error decoding image", "error", err)
+			return
+		}
+
+		t.metadata = metadata
+
+		options := t.options
+		options.Metadata = metadata
+
+		crops, hashes := computeCrops(options, img)
+		for i, cropped := range crops {
+			if options.SaveCropped {
+				saveCroppedImage(cropped, options.ImagePath, options.OutputDir, i)
+			}
+
+			encoded, err := encodeTile(cropped, i, options)
+			if err != nil {
+				t.err = err
+				slog.Error("Error encoding image", "error", err)
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				t.err = ctx.Err()
+				return
+			case ch <- Tile{Index: i, Bytes: encoded, Rect: cropped.Bounds(), Hash: hashes[i]}:
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,82 @@
+package imagetiler
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPNG(t *testing.T, width, height int) string {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating fixture: %v", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("encoding fixture: %v", err)
+	}
+	return path
+}
+
+func TestTilerTiles(t *testing.T) {
+	path := writeTestPNG(t, 20, 10)
+
+	tiler := NewTiler(Options{
+		ImagePath: path,
+		Width:     20,
+		Height:    10,
+		Mode:      ModeFit,
+	})
+
+	var tiles []Tile
+	for tile := range tiler.Tiles(context.Background()) {
+		tiles = append(tiles, tile)
+	}
+	if err := tiler.Err(); err != nil {
+		t.Fatalf("Tiles: unexpected error: %v", err)
+	}
+	if len(tiles) != 1 {
+		t.Fatalf("got %d tiles, want 1", len(tiles))
+	}
+	if len(tiles[0].Bytes) == 0 {
+		t.Error("tile 0 has no encoded bytes")
+	}
+}
+
+func TestTilerMemoryBudgetRejectsOversizedImage(t *testing.T) {
+	path := writeTestPNG(t, 100, 100)
+
+	tiler := NewTiler(Options{
+		ImagePath:         path,
+		Width:             100,
+		Height:            100,
+		Mode:              ModeFit,
+		MemoryBudgetBytes: 1024, // far smaller than 100x100x4 bytes
+	})
+
+	for range tiler.Tiles(context.Background()) {
+		t.Fatal("expected no tiles once the memory budget rejects the image")
+	}
+
+	var tooLarge *ErrImageTooLarge
+	if err := tiler.Err(); err == nil {
+		t.Fatal("expected ErrImageTooLarge, got nil")
+	} else if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected *ErrImageTooLarge, got %T: %v", err, err)
+	}
+}
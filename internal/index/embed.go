@@ -0,0 +1,24 @@
+package index
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Embed requests an embedding vector for text from an Ollama embedding
+// model (e.g. "nomic-embed-text") and narrows the response's float64
+// values to float32 for storage.
+func Embed(ctx context.Context, client *api.Client, model, text string) ([]float32, error) {
+	response, err := client.Embeddings(ctx, &api.EmbeddingRequest{Model: model, Prompt: text})
+	if err != nil {
+		return nil, fmt.Errorf("index: requesting embedding: %w", err)
+	}
+
+	vector := make([]float32, len(response.Embedding))
+	for i, v := range response.Embedding {
+		vector[i] = float32(v)
+	}
+	return vector, nil
+}
@@ -0,0 +1,210 @@
+// Package index persists image-tagger results in a SQLite database with
+// embedding vectors, so a batch of thousands of tagged images can be
+// browsed and semantically searched instead of only existing as
+// standalone "<image>_tags.json" files.
+package index
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	_ "modernc.org/sqlite"
+)
+
+// DB wraps a SQLite connection holding image-tagger's index schema.
+type DB struct {
+	conn *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS files (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	filename TEXT NOT NULL UNIQUE,
+	processed TIMESTAMP NOT NULL,
+	subject TEXT NOT NULL,
+	description TEXT NOT NULL,
+	ocr_text TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	file_id INTEGER NOT NULL REFERENCES files(id),
+	object TEXT NOT NULL,
+	confidence INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS file_embeddings (
+	file_id INTEGER PRIMARY KEY REFERENCES files(id),
+	vector BLOB NOT NULL
+);
+`
+
+// Open opens (creating if necessary) the SQLite database at path and
+// ensures the index schema exists.
+func Open(path string) (*DB, error) {
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("index: opening %s: %w", path, err)
+	}
+	if _, err := conn.Exec(schema); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("index: creating schema: %w", err)
+	}
+	return &DB{conn: conn}, nil
+}
+
+// Close closes the underlying database connection.
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+// Record is everything index.Store needs about a processed image.
+type Record struct {
+	Filename    string
+	Processed   string
+	Subject     string
+	Description string
+	OCRText     string
+	Tags        []RecordTag
+	Embedding   []float32
+}
+
+// RecordTag is a single tag attached to a Record.
+type RecordTag struct {
+	Object     string
+	Confidence int
+}
+
+// Store inserts or replaces record, its tags and its embedding vector in a
+// single transaction, keyed by filename.
+func (db *DB) Store(record Record) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("index: beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM tags WHERE file_id IN (SELECT id FROM files WHERE filename = ?)`, record.Filename); err != nil {
+		return fmt.Errorf("index: clearing old tags for %s: %w", record.Filename, err)
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO files (filename, processed, subject, description, ocr_text) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(filename) DO UPDATE SET processed = excluded.processed, subject = excluded.subject, description = excluded.description, ocr_text = excluded.ocr_text`,
+		record.Filename, record.Processed, record.Subject, record.Description, record.OCRText,
+	); err != nil {
+		return fmt.Errorf("index: upserting file %s: %w", record.Filename, err)
+	}
+
+	// ON CONFLICT DO UPDATE doesn't report the existing row's id via
+	// LastInsertId (SQLite leaves it at the connection's previous insert),
+	// so look the id up by filename.
+	var fileID int64
+	if err := tx.QueryRow(`SELECT id FROM files WHERE filename = ?`, record.Filename).Scan(&fileID); err != nil {
+		return fmt.Errorf("index: looking up file id for %s: %w", record.Filename, err)
+	}
+
+	for _, tag := range record.Tags {
+		if _, err := tx.Exec(`INSERT INTO tags (file_id, object, confidence) VALUES (?, ?, ?)`, fileID, tag.Object, tag.Confidence); err != nil {
+			return fmt.Errorf("index: inserting tag %q for %s: %w", tag.Object, record.Filename, err)
+		}
+	}
+
+	if len(record.Embedding) > 0 {
+		if _, err := tx.Exec(
+			`INSERT INTO file_embeddings (file_id, vector) VALUES (?, ?)
+			 ON CONFLICT(file_id) DO UPDATE SET vector = excluded.vector`,
+			fileID, packEmbedding(record.Embedding),
+		); err != nil {
+			return fmt.Errorf("index: storing embedding for %s: %w", record.Filename, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SearchResult is a single match returned by Search.
+type SearchResult struct {
+	Filename    string
+	Subject     string
+	Description string
+	Score       float64
+}
+
+// Search embeds query via the caller and scans every stored embedding,
+// returning the topK highest by cosine similarity. There is no vector
+// index behind this - it's a linear scan - which is fine for the
+// thousands-of-images scale this package targets.
+func (db *DB) Search(query []float32, topK int) ([]SearchResult, error) {
+	rows, err := db.conn.Query(`
+		SELECT files.filename, files.subject, files.description, file_embeddings.vector
+		FROM file_embeddings
+		JOIN files ON files.id = file_embeddings.file_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("index: querying embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var filename, subject, description string
+		var vector []byte
+		if err := rows.Scan(&filename, &subject, &description, &vector); err != nil {
+			return nil, fmt.Errorf("index: scanning embedding row: %w", err)
+		}
+		results = append(results, SearchResult{
+			Filename:    filename,
+			Subject:     subject,
+			Description: description,
+			Score:       cosineSimilarity(query, unpackEmbedding(vector)),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("index: reading embedding rows: %w", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if topK < len(results) {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// packEmbedding serializes a float32 vector as a little-endian byte blob.
+func packEmbedding(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// unpackEmbedding is the inverse of packEmbedding.
+func unpackEmbedding(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return vector
+}
+
+// cosineSimilarity returns the cosine similarity of a and b, or 0 if
+// either is the zero vector or they differ in length.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,19 @@
+// Package ocr abstracts over text-recognition backends so image-tagger can
+// feed readable on-image text (signs, UI labels, product names) into tag
+// generation without being tied to a single OCR engine.
+package ocr
+
+import "image"
+
+// Segment is a single recognized piece of text and where it was found.
+type Segment struct {
+	Text       string          `json:"text"`
+	Confidence float64         `json:"confidence"`
+	Box        image.Rectangle `json:"box"`
+}
+
+// Engine recognizes text in an encoded image (PNG/JPEG bytes, as produced
+// by imagetiler).
+type Engine interface {
+	Recognize(img []byte) ([]Segment, error)
+}
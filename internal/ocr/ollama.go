@@ -0,0 +1,80 @@
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ollama/ollama/api"
+)
+
+// ollamaOCRPrompt asks a vision model to transcribe on-image text instead
+// of describing the image. Unlike Tesseract, a general-purpose VLM has no
+// reliable notion of word-level bounding boxes, so segments it returns
+// always have a zero Box.
+const ollamaOCRPrompt = "Transcribe any text visible in the provided image (signs, labels, UI text, product names)." +
+	" Respond with JSON: an array of objects, each with " +
+	"    text: the transcribed text, " +
+	"    confidence: a confidence level number between 0 and 100. " +
+	" If no text is visible, respond with an empty array. No introductions, explanations, or extra text."
+
+const ollamaOCRFormat = `{
+	"type": "array",
+	"items": {
+		"type": "object",
+		"properties": {
+			"text": { "type": "string" },
+			"confidence": { "type": "number" }
+		},
+		"required": ["text", "confidence"]
+	}
+}`
+
+// OllamaEngine recognizes text by asking an Ollama-hosted vision model to
+// transcribe it, as a stand-in for a dedicated OCR engine when Tesseract
+// isn't available. It trades away bounding boxes (every Segment.Box is the
+// zero Rectangle) for running on the same backend already used for
+// tagging.
+type OllamaEngine struct {
+	Client *api.Client
+	Model  string
+}
+
+// NewOllamaEngine creates an OllamaEngine that asks model (via client) to
+// transcribe text out of images.
+func NewOllamaEngine(client *api.Client, model string) *OllamaEngine {
+	return &OllamaEngine{Client: client, Model: model}
+}
+
+func (e *OllamaEngine) Recognize(img []byte) ([]Segment, error) {
+	var response string
+	request := &api.GenerateRequest{
+		Model:  e.Model,
+		Prompt: ollamaOCRPrompt,
+		Stream: new(bool),
+		Images: []api.ImageData{img},
+		Format: []byte(ollamaOCRFormat),
+	}
+
+	err := e.Client.Generate(context.Background(), request, func(r api.GenerateResponse) error {
+		response = r.Response
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ocr: ollama generate: %w", err)
+	}
+
+	var entries []struct {
+		Text       string  `json:"text"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(response), &entries); err != nil {
+		return nil, fmt.Errorf("ocr: unmarshalling ollama OCR response: %w", err)
+	}
+
+	segments := make([]Segment, len(entries))
+	for i, entry := range entries {
+		segments[i] = Segment{Text: entry.Text, Confidence: entry.Confidence}
+	}
+	return segments, nil
+}
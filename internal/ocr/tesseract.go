@@ -0,0 +1,109 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// TesseractEngine recognizes text by shelling out to the tesseract CLI and
+// parsing its TSV output, which gives per-word bounding boxes and
+// confidence alongside the recognized text.
+type TesseractEngine struct {
+	// BinaryPath is the path to the tesseract executable. Defaults to
+	// "tesseract" (resolved via PATH) when empty.
+	BinaryPath string
+}
+
+// NewTesseractEngine creates a TesseractEngine. binaryPath may be empty to
+// use "tesseract" from PATH.
+func NewTesseractEngine(binaryPath string) *TesseractEngine {
+	return &TesseractEngine{BinaryPath: binaryPath}
+}
+
+func (e *TesseractEngine) Recognize(img []byte) ([]Segment, error) {
+	binary := e.BinaryPath
+	if binary == "" {
+		binary = "tesseract"
+	}
+
+	tmpFile, err := os.CreateTemp("", "image-tagger-ocr-*.png")
+	if err != nil {
+		return nil, fmt.Errorf("ocr: creating temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.Write(img); err != nil {
+		return nil, fmt.Errorf("ocr: writing temp file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return nil, fmt.Errorf("ocr: closing temp file: %w", err)
+	}
+
+	cmd := exec.Command(binary, tmpFile.Name(), "stdout", "tsv")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ocr: running tesseract: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseTesseractTSV(stdout.Bytes())
+}
+
+// parseTesseractTSV parses tesseract's --psm-agnostic TSV output, whose
+// columns are: level, page_num, block_num, par_num, line_num, word_num,
+// left, top, width, height, conf, text. Only word-level rows (level 5)
+// with non-empty text are kept.
+func parseTesseractTSV(data []byte) ([]Segment, error) {
+	var segments []Segment
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	header := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if header {
+			header = false
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 12 {
+			continue
+		}
+		if fields[0] != "5" { // level 5 == word
+			continue
+		}
+
+		text := strings.TrimSpace(fields[11])
+		if text == "" {
+			continue
+		}
+
+		left, _ := strconv.Atoi(fields[6])
+		top, _ := strconv.Atoi(fields[7])
+		width, _ := strconv.Atoi(fields[8])
+		height, _ := strconv.Atoi(fields[9])
+		conf, _ := strconv.ParseFloat(fields[10], 64)
+
+		segments = append(segments, Segment{
+			Text:       text,
+			Confidence: conf,
+			Box:        image.Rect(left, top, left+width, top+height),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ocr: reading tesseract output: %w", err)
+	}
+
+	return segments, nil
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// IndexEntry records what Process produced for a file the last time it was
+// processed, plus the file stat fields used to detect that it has changed
+// since.
+type IndexEntry struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Result  ImageData `json:"result"`
+}
+
+// Index is a resumable, crash-safe sidecar of per-file results, keyed by
+// absolute path. Batch processing consults it to skip files that haven't
+// changed since they were last processed.
+type Index struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]IndexEntry
+}
+
+// LoadIndex reads path if it exists, or starts a fresh, empty index.
+func LoadIndex(path string) (*Index, error) {
+	idx := &Index{path: path, entries: make(map[string]IndexEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &idx.entries); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Lookup reports whether path already has a result for the given mod time
+// and size, and returns it if so.
+func (idx *Index) Lookup(path string, modTime time.Time, size int64) (ImageData, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	entry, ok := idx.entries[path]
+	if !ok || !entry.ModTime.Equal(modTime) || entry.Size != size {
+		return ImageData{}, false
+	}
+	return entry.Result, true
+}
+
+// Store records result for path and persists the index to disk so a crash
+// part-way through a batch loses at most the file currently in flight. The
+// write goes through a temp file plus rename so a crash mid-write can't
+// leave idx.path itself truncated or corrupted.
+func (idx *Index) Store(path string, modTime time.Time, size int64, result ImageData) error {
+	idx.mu.Lock()
+	idx.entries[path] = IndexEntry{Path: path, ModTime: modTime, Size: size, Result: result}
+	data, err := json.MarshalIndent(idx.entries, "", "  ")
+	idx.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(idx.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(idx.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("pipeline: creating temp index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("pipeline: writing temp index file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("pipeline: closing temp index file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), idx.path); err != nil {
+		return fmt.Errorf("pipeline: renaming temp index file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Package pipeline implements the single-image tag/summarize workflow
+// shared by image-tagger's single-file and directory processing modes.
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/StrongerSoftworks/image-tagger/internal/imagereader"
+	"github.com/StrongerSoftworks/image-tagger/internal/imagetiler"
+	"github.com/StrongerSoftworks/image-tagger/internal/index"
+	"github.com/StrongerSoftworks/image-tagger/internal/ocr"
+	"github.com/StrongerSoftworks/image-tagger/internal/preproc"
+	"github.com/StrongerSoftworks/image-tagger/internal/vision"
+	"github.com/ollama/ollama/api"
+)
+
+const confidenceThreshold = 50
+
+// ImageData is the result of processing a single image.
+type ImageData struct {
+	File        string                `json:"file"`
+	Processed   time.Time             `json:"processed"`
+	Subject     string                `json:"subject"`
+	Description string                `json:"description"`
+	Tags        []vision.Tag          `json:"tags"`
+	Metadata    *imagereader.Metadata `json:"metadata,omitempty"`
+	OCR         []ocr.Segment         `json:"ocr,omitempty"`
+}
+
+// Options bundles everything Process needs besides the image path itself.
+type Options struct {
+	Backend      vision.Backend
+	TilerOptions imagetiler.Options
+	DesiredTags  []string
+	// OutputDir, when non-empty, makes Process also write
+	// "<file>_tags.json" alongside the other tile output.
+	OutputDir string
+	// OCREngine, when set, runs text recognition on the full resized
+	// image (tile 0) before tagging. Recognized text is folded into the
+	// summary prompt and recorded on ImageData.OCR. nil disables OCR.
+	OCREngine ocr.Engine
+	// Index, when set, persists each result (file, subject, description,
+	// tags, OCR text and an embedding vector) for later semantic search.
+	// nil disables indexing.
+	Index *index.DB
+	// EmbedClient and EmbedModel, when Index is set, are used to request
+	// an embedding for the concatenated subject/description/tags of each
+	// result (e.g. model "nomic-embed-text").
+	EmbedClient *api.Client
+	EmbedModel  string
+	// Preproc, when non-empty, cleans up the source image (grayscale,
+	// binarization, deskew, border wipe) before tiling. A zero-value
+	// Pipeline is a no-op.
+	Preproc preproc.Pipeline
+}
+
+// Process tiles, summarizes and tags a single image. It is the unit of
+// work shared by image-tagger's single-file (-image) and directory
+// (-dir) modes.
+func Process(ctx context.Context, imagePath string, opts Options) (ImageData, error) {
+	tilerOptions := opts.TilerOptions
+	tilerOptions.ImagePath = imagePath
+	tilerOptions.Preproc = opts.Preproc
+
+	tiler := imagetiler.NewTiler(tilerOptions)
+	var imagesData []api.ImageData
+	for tile := range tiler.Tiles(ctx) {
+		imagesData = append(imagesData, tile.Bytes)
+	}
+	if err := tiler.Err(); err != nil {
+		return ImageData{}, fmt.Errorf("pipeline: tiling %s: %w", imagePath, err)
+	}
+	metadata := tiler.Metadata()
+
+	var ocrSegments []ocr.Segment
+	summaryPrompt := ""
+	if opts.OCREngine != nil && len(imagesData) > 0 {
+		segments, err := opts.OCREngine.Recognize(imagesData[0])
+		if err != nil {
+			slog.Error("Error running OCR", "image", imagePath, "error", err)
+		} else {
+			ocrSegments = segments
+			if text := ocrText(ocrSegments); text != "" {
+				summaryPrompt = vision.SummaryPrompt + " Text visible in the image (from OCR, may be noisy): " + text
+			}
+		}
+	}
+
+	summary, err := opts.Backend.Summarize(ctx, imagesData, summaryPrompt)
+	if err != nil {
+		return ImageData{}, fmt.Errorf("pipeline: summarizing %s: %w", imagePath, err)
+	}
+
+	tags, err := opts.Backend.Tag(ctx, imagesData, summary.Subject, opts.DesiredTags)
+	if err != nil {
+		return ImageData{}, fmt.Errorf("pipeline: tagging %s: %w", imagePath, err)
+	}
+
+	result := ImageData{
+		File:        filepath.Base(imagePath),
+		Processed:   time.Now(),
+		Subject:     summary.Subject,
+		Description: summary.Description,
+		Tags:        filterUniqueTags(tags.Tags),
+		Metadata:    metadata,
+		OCR:         ocrSegments,
+	}
+
+	if opts.OutputDir != "" {
+		if err := writeResult(opts.OutputDir, result); err != nil {
+			return ImageData{}, err
+		}
+	}
+
+	if opts.Index != nil {
+		if err := indexResult(ctx, opts, result); err != nil {
+			slog.Error("Error indexing result", "image", imagePath, "error", err)
+		}
+	}
+
+	return result, nil
+}
+
+// indexResult embeds the concatenated subject/description/tags of result
+// (when opts.EmbedClient is set) and stores it, its tags and the embedding
+// in opts.Index.
+func indexResult(ctx context.Context, opts Options, result ImageData) error {
+	record := index.Record{
+		Filename:    result.File,
+		Processed:   result.Processed.Format(time.RFC3339),
+		Subject:     result.Subject,
+		Description: result.Description,
+		OCRText:     ocrText(result.OCR),
+	}
+	for _, tag := range result.Tags {
+		record.Tags = append(record.Tags, index.RecordTag{Object: tag.Object, Confidence: tag.Confidence})
+	}
+
+	if opts.EmbedClient != nil {
+		embedding, err := index.Embed(ctx, opts.EmbedClient, opts.EmbedModel, embeddingText(result))
+		if err != nil {
+			return fmt.Errorf("pipeline: embedding %s: %w", result.File, err)
+		}
+		record.Embedding = embedding
+	}
+
+	return opts.Index.Store(record)
+}
+
+// embeddingText builds the text an embedding is requested for: the
+// subject, description and tag names, which is the same information a
+// search query is matched against.
+func embeddingText(result ImageData) string {
+	parts := []string{result.Subject, result.Description}
+	for _, tag := range result.Tags {
+		parts = append(parts, tag.Object)
+	}
+	return strings.Join(parts, " ")
+}
+
+func writeResult(outputDir string, result ImageData) error {
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("pipeline: marshaling tags for %s: %w", result.File, err)
+	}
+
+	jsonFileName := fmt.Sprintf("%s_tags.json", result.File)
+	if err := os.WriteFile(path.Join(outputDir, jsonFileName), jsonData, 0644); err != nil {
+		return fmt.Errorf("pipeline: writing tags for %s: %w", result.File, err)
+	}
+	return nil
+}
+
+// ocrText joins recognized OCR segments into a single string for folding
+// into the summary prompt.
+func ocrText(segments []ocr.Segment) string {
+	words := make([]string, 0, len(segments))
+	for _, s := range segments {
+		if s.Text != "" {
+			words = append(words, s.Text)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// filterUniqueTags keeps tags with confidence at or above the threshold
+// and ensures uniqueness, preferring the highest confidence value.
+func filterUniqueTags(tags []vision.Tag) []vision.Tag {
+	tagMap := make(map[string]vision.Tag)
+
+	for _, tag := range tags {
+		if tag.Confidence < confidenceThreshold {
+			continue
+		}
+		if existingTag, exists := tagMap[tag.Object]; !exists || tag.Confidence > existingTag.Confidence {
+			tagMap[tag.Object] = tag
+		}
+	}
+
+	uniqueTags := make([]vision.Tag, 0, len(tagMap))
+	for _, tag := range tagMap {
+		uniqueTags = append(uniqueTags, tag)
+	}
+
+	return uniqueTags
+}
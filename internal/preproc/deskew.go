@@ -0,0 +1,80 @@
+package preproc
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// deskewMaxAngle bounds how far deskew will rotate an image, in either
+// direction, to straighten skewed text lines.
+const deskewMaxAngle = 15.0
+
+// deskewStep is the angle increment scanned between -deskewMaxAngle and
+// +deskewMaxAngle.
+const deskewStep = 0.5
+
+// deskewScanWidth is the width a scoring copy of the image is downscaled
+// to before each candidate rotation, to keep the search over ~60
+// candidate angles cheap.
+const deskewScanWidth = 300
+
+// deskew finds the rotation angle within [-maxAngle, maxAngle] whose
+// horizontal projection profile (row-wise sum of darkness) has the
+// highest variance - text lines that are horizontal produce sharp peaks
+// at each line and troughs between them, while skewed text smears those
+// peaks out - and rotates img by that angle.
+func deskew(img image.Image, maxAngle float64) image.Image {
+	scanImg := imaging.Resize(img, deskewScanWidth, 0, imaging.Box)
+
+	bestAngle := 0.0
+	bestVariance := -1.0
+	for angle := -maxAngle; angle <= maxAngle; angle += deskewStep {
+		rotated := imaging.Rotate(scanImg, angle, color.White)
+		variance := projectionProfileVariance(rotated)
+		if variance > bestVariance {
+			bestVariance = variance
+			bestAngle = angle
+		}
+	}
+
+	if bestAngle == 0 {
+		return img
+	}
+	return imaging.Rotate(img, bestAngle, color.White)
+}
+
+// projectionProfileVariance returns the variance of the per-row sums of
+// darkness (255-luminance) in img.
+func projectionProfileVariance(img image.Image) float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if height == 0 {
+		return 0
+	}
+
+	rowSums := make([]float64, height)
+	for y := 0; y < height; y++ {
+		sum := 0.0
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			luminance := 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+			sum += 255 - luminance
+		}
+		rowSums[y] = sum
+	}
+
+	mean := 0.0
+	for _, s := range rowSums {
+		mean += s
+	}
+	mean /= float64(height)
+
+	variance := 0.0
+	for _, s := range rowSums {
+		d := s - mean
+		variance += d * d
+	}
+	return variance / float64(height)
+}
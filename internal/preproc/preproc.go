@@ -0,0 +1,73 @@
+// Package preproc implements a configurable cleanup chain that runs on a
+// source image before imagetiler.MakeImageTiles, so document photos and
+// screenshots reach the vision model (and OCR) with less noise than the
+// raw capture: grayscale, adaptive binarization, deskew and border wipe.
+package preproc
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Step is a single preprocessing operation, selectable by name via
+// ParsePipeline/the -preproc flag.
+type Step string
+
+const (
+	// StepGrayscale converts the image to grayscale.
+	StepGrayscale Step = "grayscale"
+	// StepSauvola adaptively binarizes the image using Sauvola
+	// thresholding, implied grayscale first if the image isn't already.
+	StepSauvola Step = "sauvola"
+	// StepDeskew rotates the image to straighten skewed text lines.
+	StepDeskew Step = "deskew"
+	// StepWipe crops away near-uniform margins left by scanning/photographing
+	// a document against a background.
+	StepWipe Step = "wipe"
+)
+
+// Pipeline is an ordered chain of preprocessing steps.
+type Pipeline struct {
+	Steps []Step
+}
+
+// ParsePipeline parses a comma-separated list of step names (e.g.
+// "sauvola,deskew,wipe") into a Pipeline. An empty spec yields an empty
+// Pipeline whose Apply is a no-op.
+func ParsePipeline(spec string) (Pipeline, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Pipeline{}, nil
+	}
+
+	var steps []Step
+	for _, name := range strings.Split(spec, ",") {
+		step := Step(strings.TrimSpace(name))
+		switch step {
+		case StepGrayscale, StepSauvola, StepDeskew, StepWipe:
+			steps = append(steps, step)
+		default:
+			return Pipeline{}, fmt.Errorf("preproc: unknown step %q", name)
+		}
+	}
+	return Pipeline{Steps: steps}, nil
+}
+
+// Apply runs img through each step in order, returning the cleaned-up
+// image.
+func (p Pipeline) Apply(img image.Image) image.Image {
+	for _, step := range p.Steps {
+		switch step {
+		case StepGrayscale:
+			img = grayscale(img)
+		case StepSauvola:
+			img = sauvolaBinarize(img, sauvolaK, sauvolaWindow)
+		case StepDeskew:
+			img = deskew(img, deskewMaxAngle)
+		case StepWipe:
+			img = wipeBorders(img)
+		}
+	}
+	return img
+}
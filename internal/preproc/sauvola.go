@@ -0,0 +1,117 @@
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// sauvolaK and sauvolaWindow are the known-good defaults for Sauvola
+// thresholding on document-sized text: a window a little wider than a
+// typical line of text, and a k that favors keeping faint strokes over
+// aggressively flattening background texture.
+const (
+	sauvolaK      = 0.3
+	sauvolaWindow = 19
+	// sauvolaR is the dynamic range of grayscale standard deviation, the
+	// other constant in Sauvola's formula (fixed at its standard value for
+	// 8-bit images).
+	sauvolaR = 128.0
+)
+
+// sauvolaBinarize converts img to black-and-white using Sauvola adaptive
+// thresholding: threshold(x,y) = mean(x,y) * (1 + k*(stddev(x,y)/R - 1)),
+// where mean and stddev are computed over a window x window neighborhood.
+// Local mean/stddev are derived from two summed-area tables (sum and sum
+// of squares), so each pixel's threshold is O(1) regardless of window
+// size.
+func sauvolaBinarize(img image.Image, k float64, window int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	gray := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		gray[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			gray[y][x] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+		}
+	}
+
+	sum := buildSummedTable(gray, 1)
+	sumSq := buildSummedTable(gray, 2)
+
+	radius := window / 2
+	out := image.NewGray(bounds)
+	for y := 0; y < height; y++ {
+		y0, y1 := clamp(y-radius, 0, height-1), clamp(y+radius, 0, height-1)
+		for x := 0; x < width; x++ {
+			x0, x1 := clamp(x-radius, 0, width-1), clamp(x+radius, 0, width-1)
+			count := float64((x1 - x0 + 1) * (y1 - y0 + 1))
+
+			s := sum.rectSum(x0, y0, x1, y1)
+			sq := sumSq.rectSum(x0, y0, x1, y1)
+
+			mean := s / count
+			variance := sq/count - mean*mean
+			if variance < 0 {
+				variance = 0
+			}
+			stddev := math.Sqrt(variance)
+
+			threshold := mean * (1 + k*(stddev/sauvolaR-1))
+
+			value := uint8(0)
+			if gray[y][x] > threshold {
+				value = 255
+			}
+			out.SetGray(bounds.Min.X+x, bounds.Min.Y+y, color.Gray{Y: value})
+		}
+	}
+	return out
+}
+
+// summedTable is a summed-area table over values raised to a fixed power
+// (1 for plain sums, 2 for sums-of-squares), letting rectSum answer any
+// window's sum in O(1).
+type summedTable struct {
+	sums  []float64
+	width int
+}
+
+func buildSummedTable(values [][]float64, power int) *summedTable {
+	height := len(values)
+	width := len(values[0])
+	stride := width + 1
+
+	sums := make([]float64, (width+1)*(height+1))
+	for y := 0; y < height; y++ {
+		rowSum := 0.0
+		for x := 0; x < width; x++ {
+			v := values[y][x]
+			if power == 2 {
+				v *= v
+			}
+			rowSum += v
+			sums[(y+1)*stride+(x+1)] = sums[y*stride+(x+1)] + rowSum
+		}
+	}
+	return &summedTable{sums: sums, width: width}
+}
+
+// rectSum returns the sum of the inclusive rectangle [x0,x1] x [y0,y1].
+func (t *summedTable) rectSum(x0, y0, x1, y1 int) float64 {
+	stride := t.width + 1
+	x1, y1 = x1+1, y1+1
+	return t.sums[y1*stride+x1] - t.sums[y0*stride+x1] - t.sums[y1*stride+x0] + t.sums[y0*stride+x0]
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package preproc
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// wipeUniformityStdDev is the luminance standard deviation below which a
+// row or column is considered part of a uniform background margin rather
+// than content.
+const wipeUniformityStdDev = 8.0
+
+// wipeMaxMarginFraction caps how far inward from each edge wiping can go,
+// as a fraction of that dimension (so up to twice this fraction in total
+// per dimension), so a genuinely low-contrast (but not blank-margin) image
+// isn't cropped down to nothing.
+const wipeMaxMarginFraction = 0.25
+
+// wipeBorders crops away near-uniform margins (e.g. the background a
+// document was photographed against) by scanning inward from each edge
+// until a row or column with enough variation to be content is found.
+func wipeBorders(img image.Image) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return img
+	}
+
+	maxMarginX := int(float64(width) * wipeMaxMarginFraction)
+	maxMarginY := int(float64(height) * wipeMaxMarginFraction)
+
+	top := 0
+	for top < maxMarginY && rowStdDev(img, bounds.Min.Y+top) < wipeUniformityStdDev {
+		top++
+	}
+	bottom := 0
+	for bottom < maxMarginY && rowStdDev(img, bounds.Max.Y-1-bottom) < wipeUniformityStdDev {
+		bottom++
+	}
+	left := 0
+	for left < maxMarginX && colStdDev(img, bounds.Min.X+left) < wipeUniformityStdDev {
+		left++
+	}
+	right := 0
+	for right < maxMarginX && colStdDev(img, bounds.Max.X-1-right) < wipeUniformityStdDev {
+		right++
+	}
+
+	if top == 0 && bottom == 0 && left == 0 && right == 0 {
+		return img
+	}
+
+	rect := image.Rect(bounds.Min.X+left, bounds.Min.Y+top, bounds.Max.X-right, bounds.Max.Y-bottom)
+	if rect.Dx() <= 0 || rect.Dy() <= 0 {
+		return img
+	}
+	return imaging.Crop(img, rect)
+}
+
+func rowStdDev(img image.Image, y int) float64 {
+	bounds := img.Bounds()
+	return luminanceStdDev(img, bounds.Min.X, bounds.Max.X, y, y+1)
+}
+
+func colStdDev(img image.Image, x int) float64 {
+	bounds := img.Bounds()
+	return luminanceStdDev(img, x, x+1, bounds.Min.Y, bounds.Max.Y)
+}
+
+// luminanceStdDev returns the standard deviation of luminance over
+// [x0,x1) x [y0,y1).
+func luminanceStdDev(img image.Image, x0, x1, y0, y1 int) float64 {
+	var sum, sumSq float64
+	count := 0
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			luminance := 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+			sum += luminance
+			sumSq += luminance * luminance
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	mean := sum / float64(count)
+	variance := sumSq/float64(count) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return math.Sqrt(variance)
+}
@@ -0,0 +1,44 @@
+package vision
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Kind identifies which Backend implementation to construct.
+type Kind string
+
+const (
+	KindOllama   Kind = "ollama"
+	KindOpenAI   Kind = "openai"
+	KindLlamaCpp Kind = "llamacpp"
+)
+
+// New builds a Backend for kind, reading per-backend configuration from
+// the environment:
+//
+//   - ollama:   uses ollamaClient (from api.ClientFromEnvironment)
+//   - openai:   OPENAI_API_KEY, OPENAI_BASE_URL (default https://api.openai.com/v1)
+//   - llamacpp: LLAMACPP_URL (default http://localhost:8080)
+func New(kind Kind, model string, ollamaClient *api.Client) (Backend, error) {
+	switch kind {
+	case "", KindOllama:
+		return NewOllamaBackend(ollamaClient, model), nil
+	case KindOpenAI:
+		baseURL := os.Getenv("OPENAI_BASE_URL")
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		return NewOpenAIBackend(baseURL, os.Getenv("OPENAI_API_KEY"), model), nil
+	case KindLlamaCpp:
+		baseURL := os.Getenv("LLAMACPP_URL")
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+		}
+		return NewLlamaCppBackend(baseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown vision backend %q", kind)
+	}
+}
@@ -0,0 +1,111 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ollama/ollama/api"
+)
+
+// LlamaCppBackend talks to a llama.cpp HTTP server's /completion endpoint,
+// passing tiles via image_data and asking for the subject/tags JSON inline
+// in the prompt, since the grammar parameter only constrains token-level
+// shape rather than accepting a JSON Schema directly.
+type LlamaCppBackend struct {
+	HTTPClient *http.Client
+	BaseURL    string
+}
+
+func NewLlamaCppBackend(baseURL string) *LlamaCppBackend {
+	return &LlamaCppBackend{HTTPClient: http.DefaultClient, BaseURL: baseURL}
+}
+
+type llamaCppImageData struct {
+	Data string `json:"data"`
+	ID   int    `json:"id"`
+}
+
+type llamaCppRequest struct {
+	Prompt    string              `json:"prompt"`
+	ImageData []llamaCppImageData `json:"image_data"`
+	Grammar   string              `json:"grammar,omitempty"`
+}
+
+type llamaCppResponse struct {
+	Content string `json:"content"`
+}
+
+func (b *LlamaCppBackend) Summarize(ctx context.Context, images []api.ImageData, prompt string) (VisionModelSummary, error) {
+	if prompt == "" {
+		prompt = SummaryPrompt
+	}
+
+	var summary VisionModelSummary
+	content, err := b.complete(ctx, images, prompt+" Respond with JSON matching this schema: "+summaryFormat)
+	if err != nil {
+		return VisionModelSummary{}, err
+	}
+	if err := json.Unmarshal([]byte(content), &summary); err != nil {
+		return VisionModelSummary{}, fmt.Errorf("llamacpp summarize: unmarshalling response: %w", err)
+	}
+	return summary, nil
+}
+
+func (b *LlamaCppBackend) Tag(ctx context.Context, images []api.ImageData, subject string, allowed []string) (VisionModelTags, error) {
+	var tags VisionModelTags
+	prompt := tagPrompt(subject, allowed) + " Respond with JSON matching this schema: " + tagsFormat
+	content, err := b.complete(ctx, images, prompt)
+	if err != nil {
+		return VisionModelTags{}, err
+	}
+	if err := json.Unmarshal([]byte(content), &tags); err != nil {
+		return VisionModelTags{}, fmt.Errorf("llamacpp tag: unmarshalling response: %w", err)
+	}
+	return tags, nil
+}
+
+func (b *LlamaCppBackend) complete(ctx context.Context, images []api.ImageData, prompt string) (string, error) {
+	imageData := make([]llamaCppImageData, len(images))
+	imageRefs := ""
+	for i, img := range images {
+		imageData[i] = llamaCppImageData{Data: base64.StdEncoding.EncodeToString(img), ID: i + 10}
+		imageRefs += fmt.Sprintf("[img-%d]", i+10)
+	}
+
+	reqBody := llamaCppRequest{
+		Prompt:    imageRefs + " " + prompt,
+		ImageData: imageData,
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("llamacpp: marshaling request: %w", err)
+	}
+
+	url := b.BaseURL + "/completion"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("llamacpp: building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("llamacpp: sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llamacpp: unexpected status %d", resp.StatusCode)
+	}
+
+	var parsed llamaCppResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", fmt.Errorf("llamacpp: decoding response: %w", err)
+	}
+	return parsed.Content, nil
+}
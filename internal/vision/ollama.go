@@ -0,0 +1,65 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ollama/ollama/api"
+)
+
+// OllamaBackend talks to a local (or remote) Ollama server via its
+// /api/generate endpoint, using the Format field to request structured
+// JSON output.
+type OllamaBackend struct {
+	Client *api.Client
+	Model  string
+}
+
+func NewOllamaBackend(client *api.Client, model string) *OllamaBackend {
+	return &OllamaBackend{Client: client, Model: model}
+}
+
+func (b *OllamaBackend) Summarize(ctx context.Context, images []api.ImageData, prompt string) (VisionModelSummary, error) {
+	if prompt == "" {
+		prompt = SummaryPrompt
+	}
+
+	request := &api.GenerateRequest{
+		Model:  b.Model,
+		Prompt: prompt,
+		Stream: new(bool),
+		Images: images,
+		Format: []byte(summaryFormat),
+	}
+
+	var summary VisionModelSummary
+	responseHandler := func(response api.GenerateResponse) error {
+		return json.Unmarshal([]byte(response.Response), &summary)
+	}
+
+	if err := b.Client.Generate(ctx, request, responseHandler); err != nil {
+		return VisionModelSummary{}, fmt.Errorf("ollama summarize: %w", err)
+	}
+	return summary, nil
+}
+
+func (b *OllamaBackend) Tag(ctx context.Context, images []api.ImageData, subject string, allowed []string) (VisionModelTags, error) {
+	request := &api.GenerateRequest{
+		Model:  b.Model,
+		Prompt: tagPrompt(subject, allowed),
+		Stream: new(bool),
+		Images: images,
+		Format: []byte(tagsFormat),
+	}
+
+	var tags VisionModelTags
+	responseHandler := func(response api.GenerateResponse) error {
+		return json.Unmarshal([]byte(response.Response), &tags)
+	}
+
+	if err := b.Client.Generate(ctx, request, responseHandler); err != nil {
+		return VisionModelTags{}, fmt.Errorf("ollama tag: %w", err)
+	}
+	return tags, nil
+}
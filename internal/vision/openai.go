@@ -0,0 +1,147 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ollama/ollama/api"
+)
+
+// OpenAIBackend talks to an OpenAI-compatible chat completions endpoint,
+// sending tiles as base64 data URIs and requesting structured output via
+// response_format: json_schema.
+type OpenAIBackend struct {
+	HTTPClient *http.Client
+	BaseURL    string
+	APIKey     string
+	Model      string
+}
+
+func NewOpenAIBackend(baseURL, apiKey, model string) *OpenAIBackend {
+	return &OpenAIBackend{HTTPClient: http.DefaultClient, BaseURL: baseURL, APIKey: apiKey, Model: model}
+}
+
+type openAIMessageContent struct {
+	Type     string             `json:"type"`
+	Text     string             `json:"text,omitempty"`
+	ImageURL *openAIImageURLRef `json:"image_url,omitempty"`
+}
+
+type openAIImageURLRef struct {
+	URL string `json:"url"`
+}
+
+type openAIRequest struct {
+	Model          string                  `json:"model"`
+	Messages       []openAIMessage         `json:"messages"`
+	ResponseFormat openAIJSONSchemaWrapper `json:"response_format"`
+}
+
+type openAIMessage struct {
+	Role    string                 `json:"role"`
+	Content []openAIMessageContent `json:"content"`
+}
+
+type openAIJSONSchemaWrapper struct {
+	Type       string           `json:"type"`
+	JSONSchema openAIJSONSchema `json:"json_schema"`
+}
+
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Strict bool            `json:"strict"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+func (b *OpenAIBackend) Summarize(ctx context.Context, images []api.ImageData, prompt string) (VisionModelSummary, error) {
+	if prompt == "" {
+		prompt = SummaryPrompt
+	}
+
+	var summary VisionModelSummary
+	content, err := b.complete(ctx, images, prompt, "image_summary", summaryFormat)
+	if err != nil {
+		return VisionModelSummary{}, err
+	}
+	if err := json.Unmarshal([]byte(content), &summary); err != nil {
+		return VisionModelSummary{}, fmt.Errorf("openai summarize: unmarshalling response: %w", err)
+	}
+	return summary, nil
+}
+
+func (b *OpenAIBackend) Tag(ctx context.Context, images []api.ImageData, subject string, allowed []string) (VisionModelTags, error) {
+	var tags VisionModelTags
+	content, err := b.complete(ctx, images, tagPrompt(subject, allowed), "image_tags", tagsFormat)
+	if err != nil {
+		return VisionModelTags{}, err
+	}
+	if err := json.Unmarshal([]byte(content), &tags); err != nil {
+		return VisionModelTags{}, fmt.Errorf("openai tag: unmarshalling response: %w", err)
+	}
+	return tags, nil
+}
+
+func (b *OpenAIBackend) complete(ctx context.Context, images []api.ImageData, prompt, schemaName, schema string) (string, error) {
+	content := []openAIMessageContent{{Type: "text", Text: prompt}}
+	for _, img := range images {
+		dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(img)
+		content = append(content, openAIMessageContent{Type: "image_url", ImageURL: &openAIImageURLRef{URL: dataURL}})
+	}
+
+	reqBody := openAIRequest{
+		Model:    b.Model,
+		Messages: []openAIMessage{{Role: "user", Content: content}},
+		ResponseFormat: openAIJSONSchemaWrapper{
+			Type: "json_schema",
+			JSONSchema: openAIJSONSchema{
+				Name:   schemaName,
+				Strict: true,
+				Schema: json.RawMessage(schema),
+			},
+		},
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("openai: marshaling request: %w", err)
+	}
+
+	url := b.BaseURL + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("openai: building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+b.APIKey)
+
+	resp, err := b.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("openai: sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("openai: unexpected status %d", resp.StatusCode)
+	}
+
+	var parsed openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", fmt.Errorf("openai: decoding response: %w", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("openai: no choices in response")
+	}
+	return parsed.Choices[0].Message.Content, nil
+}
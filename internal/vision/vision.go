@@ -0,0 +1,110 @@
+// Package vision abstracts over the different vision model transports
+// (Ollama, OpenAI-compatible chat completions, a llama.cpp HTTP server) so
+// the rest of image-tagger can summarize and tag images without caring
+// which one is actually deployed.
+package vision
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Tag is a single detected object and the backend's confidence in it.
+type Tag struct {
+	Object     string `json:"object"`
+	Confidence int    `json:"confidence"`
+}
+
+// VisionModelTags is the structured response of a Tag request.
+type VisionModelTags struct {
+	Subject string `json:"subject"`
+	Tags    []Tag  `json:"tags"`
+}
+
+// VisionModelSummary is the structured response of a Summarize request.
+type VisionModelSummary struct {
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+}
+
+// Backend generates a subject/description summary and a list of tags for a
+// set of image tiles. Implementations translate the shared prompts and JSON
+// schema below into whatever structured-output mechanism their transport
+// supports (Ollama's Format field, OpenAI's response_format). The llama.cpp
+// backend sends no grammar; it appends the schema to the prompt as text.
+type Backend interface {
+	Summarize(ctx context.Context, images []api.ImageData, prompt string) (VisionModelSummary, error)
+	Tag(ctx context.Context, images []api.ImageData, subject string, allowed []string) (VisionModelTags, error)
+}
+
+// SummaryPrompt is the default instruction used to ask a backend for a
+// subject and short description of an image.
+const SummaryPrompt = "You are a professional SEO specialist. Analyze the provided image and provide:" +
+	"    subject: The main subject of the image as a single word. The subject can be an object or improper noun." +
+	"    description: A short description of the image no longer than 20 words." +
+	" No introductions, explanations, or extra text." +
+	" Respond using JSON."
+
+// summaryFormat is the JSON schema for a VisionModelSummary response.
+// additionalProperties is set to false on the object, as required by
+// OpenAI's Structured Outputs strict mode (see OpenAIBackend.complete).
+const summaryFormat = `{
+	"type": "object",
+	"properties": {
+		"subject": { "type": "string" },
+		"description": { "type": "string" }
+	},
+	"required": [
+		"subject", "description"
+	],
+	"additionalProperties": false
+}`
+
+// tagsFormat is the JSON schema for a VisionModelTags response.
+// additionalProperties is set to false on both the outer object and the
+// nested tag object, as required by OpenAI's Structured Outputs strict
+// mode (see OpenAIBackend.complete).
+const tagsFormat = `{
+	"type": "object",
+	"properties": {
+		"tags": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"object": {
+						"type": "string"
+					},
+					"confidence": {
+						"type": "number"
+					}
+				},
+				"required": ["object", "confidence"],
+				"additionalProperties": false
+			}
+		}
+	},
+	"required": [
+		"tags"
+	],
+	"additionalProperties": false
+}`
+
+// tagPrompt builds the instruction used to ask a backend for tags, either
+// constrained to an allowed list or free-form when allowed is empty.
+func tagPrompt(subject string, allowed []string) string {
+	objectInstruction := "that are visible in the image"
+	if len(allowed) > 0 {
+		objectInstruction = "from the following list: [" + strings.Join(allowed, ", ") + "]"
+	}
+	return "You are assembling a list of tags for a web application that will be used for browsing images and filtering images by tags." +
+		" Analyze the provided image of a " + subject + " and identify the objects " + objectInstruction + "." +
+		" If an object is found, provide: " +
+		"    object: An object from the list of objects." +
+		"    confidence: A confidence level number between 0 and 100 based on clarity, visibility, and similarity to known references." +
+		" The object should clearly be visible in the image and you must me confident that the object is correctly identified." +
+		" No introductions, explanations, or extra text." +
+		" Respond using JSON."
+}
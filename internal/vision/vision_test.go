@@ -0,0 +1,73 @@
+package vision
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestStructuredOutputSchemasAreStrictModeCompliant checks summaryFormat and
+// tagsFormat against OpenAI's Structured Outputs strict mode constraints:
+// https://platform.openai.com/docs/guides/structured-outputs - every object
+// in the schema must set "additionalProperties": false and list every one
+// of its properties in "required". OpenAIBackend.complete sends both
+// schemas with strict: true, so a schema that violates this gets rejected
+// with a 400 on every real request.
+func TestStructuredOutputSchemasAreStrictModeCompliant(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		schema string
+	}{
+		{"summaryFormat", summaryFormat},
+		{"tagsFormat", tagsFormat},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var node map[string]any
+			if err := json.Unmarshal([]byte(tc.schema), &node); err != nil {
+				t.Fatalf("parsing schema: %v", err)
+			}
+			assertStrictModeCompliant(t, tc.name, node)
+		})
+	}
+}
+
+// assertStrictModeCompliant recursively walks a JSON schema node, requiring
+// every "object" node to set additionalProperties: false and require all of
+// its declared properties.
+func assertStrictModeCompliant(t *testing.T, path string, node map[string]any) {
+	t.Helper()
+
+	if node["type"] != "object" {
+		return
+	}
+
+	if additional, ok := node["additionalProperties"].(bool); !ok || additional {
+		t.Errorf("%s: object must set \"additionalProperties\": false", path)
+	}
+
+	properties, _ := node["properties"].(map[string]any)
+	required, _ := node["required"].([]any)
+	requiredSet := make(map[string]bool, len(required))
+	for _, r := range required {
+		requiredSet[r.(string)] = true
+	}
+	for name := range properties {
+		if !requiredSet[name] {
+			t.Errorf("%s: property %q must be listed in \"required\"", path, name)
+		}
+	}
+
+	for name, prop := range properties {
+		propNode, ok := prop.(map[string]any)
+		if !ok {
+			continue
+		}
+		childPath := path + "." + name
+		if propNode["type"] == "array" {
+			if items, ok := propNode["items"].(map[string]any); ok {
+				assertStrictModeCompliant(t, childPath+".items", items)
+			}
+			continue
+		}
+		assertStrictModeCompliant(t, childPath, propNode)
+	}
+}